@@ -0,0 +1,151 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandleALB processes a request from an Application Load Balancer target group.
+// When the target group has multi-value headers enabled, the request's multi-value
+// fields are used and the response is returned with MultiValueHeaders only.
+func (r *LambdaMux) HandleALB(ctx context.Context, req events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	multiValue := req.MultiValueHeaders != nil || req.MultiValueQueryStringParameters != nil
+
+	resp, err := r.Handle(ctx, r.decodeRawPath(albToProxyRequest(req, multiValue)))
+	if err != nil {
+		return events.ALBTargetGroupResponse{}, err
+	}
+
+	return proxyToALBResponse(resp, multiValue), nil
+}
+
+// albToProxyRequest converts an ALB request to the REST API format used by handlers.
+// ALB passes query parameters without decoding them, so they are unescaped here.
+func albToProxyRequest(req events.ALBTargetGroupRequest, multiValue bool) events.APIGatewayProxyRequest {
+	proxyReq := events.APIGatewayProxyRequest{
+		Path:            req.Path,
+		HTTPMethod:      req.HTTPMethod,
+		Body:            req.Body,
+		IsBase64Encoded: req.IsBase64Encoded,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Path:       req.Path,
+			HTTPMethod: req.HTTPMethod,
+		},
+	}
+
+	if multiValue {
+		proxyReq.MultiValueHeaders = req.MultiValueHeaders
+		proxyReq.Headers = lastValues(req.MultiValueHeaders)
+		proxyReq.MultiValueQueryStringParameters = unescapeMultiValueQuery(req.MultiValueQueryStringParameters)
+		proxyReq.QueryStringParameters = lastValues(proxyReq.MultiValueQueryStringParameters)
+		return proxyReq
+	}
+
+	proxyReq.Headers = req.Headers
+	proxyReq.MultiValueHeaders = splitHeaderValues(req.Headers, nil)
+	proxyReq.QueryStringParameters = unescapeQuery(req.QueryStringParameters)
+	proxyReq.MultiValueQueryStringParameters = singleValues(proxyReq.QueryStringParameters)
+	return proxyReq
+}
+
+// proxyToALBResponse converts a handler response to the ALB format. In multi-value mode
+// ALB ignores the Headers field, so all headers are moved to MultiValueHeaders.
+func proxyToALBResponse(resp events.APIGatewayProxyResponse, multiValue bool) events.ALBTargetGroupResponse {
+	albResp := events.ALBTargetGroupResponse{
+		StatusCode:        resp.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		Body:              resp.Body,
+		IsBase64Encoded:   resp.IsBase64Encoded,
+	}
+
+	if multiValue {
+		headers := make(map[string][]string, len(resp.Headers)+len(resp.MultiValueHeaders))
+		for k, v := range resp.Headers {
+			headers[k] = []string{v}
+		}
+		for k, v := range resp.MultiValueHeaders {
+			headers[k] = v
+		}
+		albResp.MultiValueHeaders = headers
+		return albResp
+	}
+
+	headers := make(map[string]string, len(resp.Headers)+len(resp.MultiValueHeaders))
+	for k, v := range resp.MultiValueHeaders {
+		headers[k] = strings.Join(v, ",")
+	}
+	for k, v := range resp.Headers {
+		headers[k] = v
+	}
+	albResp.Headers = headers
+	return albResp
+}
+
+// lastValues returns the last value for each key, matching how API Gateway fills single-value fields
+func lastValues(values map[string][]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) > 0 {
+			result[k] = v[len(v)-1]
+		}
+	}
+	return result
+}
+
+// singleValues wraps each value in a single element slice
+func singleValues(values map[string]string) map[string][]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string][]string, len(values))
+	for k, v := range values {
+		result[k] = []string{v}
+	}
+	return result
+}
+
+// unescapeQuery decodes percent-encoded query parameter keys and values
+func unescapeQuery(query map[string]string) map[string]string {
+	if query == nil {
+		return nil
+	}
+	result := make(map[string]string, len(query))
+	for k, v := range query {
+		result[queryUnescape(k)] = queryUnescape(v)
+	}
+	return result
+}
+
+// unescapeMultiValueQuery decodes percent-encoded multi-value query parameter keys and values
+func unescapeMultiValueQuery(query map[string][]string) map[string][]string {
+	if query == nil {
+		return nil
+	}
+	result := make(map[string][]string, len(query))
+	for k, v := range query {
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = queryUnescape(v[i])
+		}
+		key := queryUnescape(k)
+		result[key] = append(result[key], values...)
+	}
+	return result
+}
+
+// queryUnescape decodes s, returning it unchanged if it is not validly encoded
+func queryUnescape(s string) string {
+	unescaped, err := url.QueryUnescape(s)
+	if err != nil {
+		return s
+	}
+	return unescaped
+}
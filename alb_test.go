@@ -0,0 +1,132 @@
+package lambdamux
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleALB(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/pet/findByStatus", createHandler("GET", "/pet/findByStatus"))
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+
+	testCases := []struct {
+		id                  int
+		name                string
+		method              string
+		path                string
+		expectedStatus      int
+		expectedDescription string
+		expectedBody        string
+	}{
+		{
+			1,
+			"static route",
+			"GET",
+			"/pet/findByStatus",
+			200,
+			"200 OK",
+			`{"message":"Handled GET request for /pet/findByStatus"}`,
+		},
+		{
+			2,
+			"param route",
+			"GET",
+			"/pet/123",
+			200,
+			"200 OK",
+			`{"message":"Handled GET request for /pet/:petId","params":{"petId":"123"}}`,
+		},
+		{3, "not found", "GET", "/nonexistent", 404, "404 Not Found", `{"error": "404 Not Found"}`},
+		{
+			4,
+			"encoded param",
+			"GET",
+			"/pet/a%20b",
+			200,
+			"200 OK",
+			`{"message":"Handled GET request for /pet/:petId","params":{"petId":"a b"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		req := events.ALBTargetGroupRequest{HTTPMethod: tc.method, Path: tc.path}
+		resp, err := router.HandleALB(context.Background(), req)
+
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+		assert.Equal(t, tc.expectedDescription, resp.StatusDescription, "Test case %d: %s - Status description mismatch", tc.id, tc.name)
+		assert.JSONEq(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+		assert.Equal(t, "application/json", resp.Headers["Content-Type"], "Test case %d: %s - Header mismatch", tc.id, tc.name)
+		assert.Nil(t, resp.MultiValueHeaders, "Test case %d: %s - Unexpected multi-value headers", tc.id, tc.name)
+	}
+}
+
+func TestHandleALBSingleValue(t *testing.T) {
+	router := NewLambdaMux()
+
+	var got events.APIGatewayProxyRequest
+	router.GET("/search", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = req
+		return events.APIGatewayProxyResponse{
+			StatusCode:        200,
+			MultiValueHeaders: map[string][]string{"Cache-Control": {"no-cache", "no-store"}},
+		}, nil
+	})
+
+	req := events.ALBTargetGroupRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/search",
+		Headers:               map[string]string{"accept": "application/json"},
+		QueryStringParameters: map[string]string{"q": "hello%20world"},
+	}
+	resp, err := router.HandleALB(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", got.QueryStringParameters["q"])
+	assert.Equal(t, []string{"hello world"}, got.MultiValueQueryStringParameters["q"])
+	assert.Equal(t, "application/json", got.Headers["accept"])
+	assert.Equal(t, map[string]string{"Cache-Control": "no-cache,no-store"}, resp.Headers)
+	assert.Nil(t, resp.MultiValueHeaders)
+}
+
+func TestHandleALBMultiValue(t *testing.T) {
+	router := NewLambdaMux()
+
+	var got events.APIGatewayProxyRequest
+	router.GET("/search", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = req
+		return events.APIGatewayProxyResponse{
+			StatusCode:        201,
+			Headers:           map[string]string{"Content-Type": "text/plain"},
+			MultiValueHeaders: map[string][]string{"Set-Cookie": {"a=1", "b=2"}},
+		}, nil
+	})
+
+	req := events.ALBTargetGroupRequest{
+		HTTPMethod: "GET",
+		Path:       "/search",
+		MultiValueHeaders: map[string][]string{
+			"accept": {"application/json"},
+			"x-tag":  {"one", "two"},
+		},
+		MultiValueQueryStringParameters: map[string][]string{"tag": {"a%2Bb", "c"}},
+	}
+	resp, err := router.HandleALB(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "201 Created", resp.StatusDescription)
+	assert.Equal(t, []string{"a+b", "c"}, got.MultiValueQueryStringParameters["tag"])
+	assert.Equal(t, "c", got.QueryStringParameters["tag"])
+	assert.Equal(t, []string{"one", "two"}, got.MultiValueHeaders["x-tag"])
+	assert.Equal(t, "two", got.Headers["x-tag"])
+	assert.Nil(t, resp.Headers)
+	assert.Equal(t, map[string][]string{
+		"Content-Type": {"text/plain"},
+		"Set-Cookie":   {"a=1", "b=2"},
+	}, resp.MultiValueHeaders)
+}
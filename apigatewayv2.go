@@ -0,0 +1,151 @@
+package lambdamux
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandleV2 processes an API Gateway HTTP API (payload format 2.0) request.
+// The request is routed through the same routes as Handle, so handlers
+// registered once serve both payload formats.
+func (r *LambdaMux) HandleV2(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	resp, err := r.Handle(ctx, r.decodeRawPath(v2ToProxyRequest(req)))
+	if err != nil {
+		return events.APIGatewayV2HTTPResponse{}, err
+	}
+
+	return proxyToV2Response(resp), nil
+}
+
+// v2ToProxyRequest converts an HTTP API request to the REST API format used by handlers
+func v2ToProxyRequest(req events.APIGatewayV2HTTPRequest) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Resource:                        req.RouteKey,
+		Path:                            req.RawPath,
+		HTTPMethod:                      req.RequestContext.HTTP.Method,
+		Headers:                         headersWithCookies(req.Headers, req.Cookies),
+		MultiValueHeaders:               splitHeaderValues(req.Headers, req.Cookies),
+		QueryStringParameters:           req.QueryStringParameters,
+		MultiValueQueryStringParameters: multiValueQuery(req.RawQueryString),
+		PathParameters:                  req.PathParameters,
+		StageVariables:                  req.StageVariables,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    req.RequestContext.AccountID,
+			Stage:        req.RequestContext.Stage,
+			DomainName:   req.RequestContext.DomainName,
+			DomainPrefix: req.RequestContext.DomainPrefix,
+			RequestID:    req.RequestContext.RequestID,
+			Protocol:     req.RequestContext.HTTP.Protocol,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  req.RequestContext.HTTP.SourceIP,
+				UserAgent: req.RequestContext.HTTP.UserAgent,
+			},
+			Path:             req.RequestContext.HTTP.Path,
+			HTTPMethod:       req.RequestContext.HTTP.Method,
+			RequestTime:      req.RequestContext.Time,
+			RequestTimeEpoch: req.RequestContext.TimeEpoch,
+			APIID:            req.RequestContext.APIID,
+		},
+		Body:            req.Body,
+		IsBase64Encoded: req.IsBase64Encoded,
+	}
+}
+
+// proxyToV2Response converts a handler response to the HTTP API format, moving any Set-Cookie
+// headers to the Cookies field. Payload format 2.0 has no multi-value headers, so they are joined
+// into Headers.
+func proxyToV2Response(resp events.APIGatewayProxyResponse) events.APIGatewayV2HTTPResponse {
+	headers, cookies := flattenHeaders(resp)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      resp.StatusCode,
+		Headers:         headers,
+		Body:            resp.Body,
+		IsBase64Encoded: resp.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// headersWithCookies returns a copy of headers with the cookies joined into a single cookie header
+func headersWithCookies(headers map[string]string, cookies []string) map[string]string {
+	if len(cookies) == 0 {
+		return headers
+	}
+	result := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		result[k] = v
+	}
+	result["cookie"] = strings.Join(cookies, "; ")
+	return result
+}
+
+// splitHeaderValues splits comma-joined header values into their multi-value form
+func splitHeaderValues(headers map[string]string, cookies []string) map[string][]string {
+	if len(headers) == 0 && len(cookies) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(headers)+1)
+	for k, v := range headers {
+		values := strings.Split(v, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		result[k] = values
+	}
+	if len(cookies) > 0 {
+		result["cookie"] = cookies
+	}
+	return result
+}
+
+// multiValueQuery parses a raw query string into its multi-value form
+func multiValueQuery(rawQuery string) map[string][]string {
+	if rawQuery == "" {
+		return nil
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+// extractCookies removes Set-Cookie headers from the given headers and returns them separately
+func extractCookies(
+	headers map[string]string,
+	multiValueHeaders map[string][]string,
+) (map[string]string, map[string][]string, []string) {
+	var cookies []string
+	for k, v := range multiValueHeaders {
+		if strings.EqualFold(k, "Set-Cookie") {
+			cookies = append(cookies, v...)
+		}
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, "Set-Cookie") && !slices.Contains(cookies, v) {
+			cookies = append(cookies, v)
+		}
+	}
+	if len(cookies) == 0 {
+		return headers, multiValueHeaders, nil
+	}
+
+	return withoutSetCookie(headers), withoutSetCookie(multiValueHeaders), cookies
+}
+
+// withoutSetCookie returns a copy of the given headers without Set-Cookie entries
+func withoutSetCookie[T any](headers map[string]T) map[string]T {
+	if headers == nil {
+		return nil
+	}
+	result := make(map[string]T, len(headers))
+	for k, v := range headers {
+		if !strings.EqualFold(k, "Set-Cookie") {
+			result[k] = v
+		}
+	}
+	return result
+}
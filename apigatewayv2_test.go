@@ -0,0 +1,192 @@
+package lambdamux
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func newV2Request(method, path string) events.APIGatewayV2HTTPRequest {
+	return events.APIGatewayV2HTTPRequest{
+		Version: "2.0",
+		RawPath: path,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method: method,
+				Path:   path,
+			},
+		},
+	}
+}
+
+func TestHandleV2(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/pet/findByStatus", createHandler("GET", "/pet/findByStatus"))
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+	router.DELETE("/pet/:petId", createHandler("DELETE", "/pet/:petId"))
+
+	testCases := []struct {
+		id             int
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			1,
+			"static route",
+			"GET",
+			"/pet/findByStatus",
+			200,
+			`{"message":"Handled GET request for /pet/findByStatus"}`,
+		},
+		{
+			2,
+			"param route",
+			"GET",
+			"/pet/123",
+			200,
+			`{"message":"Handled GET request for /pet/:petId","params":{"petId":"123"}}`,
+		},
+		{
+			3,
+			"param route with another method",
+			"DELETE",
+			"/pet/456",
+			200,
+			`{"message":"Handled DELETE request for /pet/:petId","params":{"petId":"456"}}`,
+		},
+		{4, "not found", "GET", "/nonexistent", 404, `{"error": "404 Not Found"}`},
+	}
+
+	for _, tc := range testCases {
+		resp, err := router.HandleV2(context.Background(), newV2Request(tc.method, tc.path))
+
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+		assert.JSONEq(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+	}
+}
+
+func TestHandleV2Request(t *testing.T) {
+	router := NewLambdaMux()
+
+	var got events.APIGatewayProxyRequest
+	router.POST("/users/:id", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = req
+		return events.APIGatewayProxyResponse{StatusCode: 204}, nil
+	})
+
+	req := newV2Request("POST", "/users/42")
+	req.RawQueryString = "tag=a&tag=b"
+	req.QueryStringParameters = map[string]string{"tag": "a,b"}
+	req.Headers = map[string]string{"accept": "text/html, application/json"}
+	req.Cookies = []string{"session=abc", "theme=dark"}
+	req.Body = "aGVsbG8="
+	req.IsBase64Encoded = true
+	req.RequestContext.Stage = "$default"
+	req.RequestContext.RequestID = "request-id"
+
+	resp, err := router.HandleV2(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 204, resp.StatusCode)
+	assert.Equal(t, "POST", got.HTTPMethod)
+	assert.Equal(t, "/users/42", got.Path)
+	assert.Equal(t, map[string]string{"id": "42"}, got.PathParameters)
+	assert.Equal(t, "session=abc; theme=dark", got.Headers["cookie"])
+	assert.Equal(t, []string{"session=abc", "theme=dark"}, got.MultiValueHeaders["cookie"])
+	assert.Equal(t, []string{"text/html", "application/json"}, got.MultiValueHeaders["accept"])
+	assert.Equal(t, []string{"a", "b"}, got.MultiValueQueryStringParameters["tag"])
+	assert.Equal(t, "a,b", got.QueryStringParameters["tag"])
+	assert.Equal(t, "aGVsbG8=", got.Body)
+	assert.True(t, got.IsBase64Encoded)
+	assert.Equal(t, "$default", got.RequestContext.Stage)
+	assert.Equal(t, "request-id", got.RequestContext.RequestID)
+}
+
+func TestHandleV2Cookies(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/login", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+			Headers:    map[string]string{"Content-Type": "text/plain"},
+			MultiValueHeaders: map[string][]string{
+				"Set-Cookie": {"session=abc; HttpOnly", "theme=dark"},
+			},
+			Body: "ok",
+		}, nil
+	})
+
+	resp, err := router.HandleV2(context.Background(), newV2Request("GET", "/login"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "ok", resp.Body)
+	assert.Equal(t, []string{"session=abc; HttpOnly", "theme=dark"}, resp.Cookies)
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain"}, resp.Headers)
+	assert.Nil(t, resp.MultiValueHeaders)
+}
+
+func TestHandleV2MultiValueHeaders(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{
+			StatusCode:        200,
+			Headers:           map[string]string{"Content-Type": "application/json"},
+			MultiValueHeaders: map[string][]string{"Vary": {"Accept", "Origin"}},
+		}, nil
+	})
+
+	resp, err := router.HandleV2(context.Background(), newV2Request("GET", "/users"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "Vary": "Accept,Origin"}, resp.Headers)
+	assert.Nil(t, resp.MultiValueHeaders)
+}
+
+func TestHandleV2EncodedPath(t *testing.T) {
+	testCases := []struct {
+		id             int
+		name           string
+		useRawPath     bool
+		rawPath        string
+		expectedPath   string
+		expectedParams map[string]string
+	}{
+		{1, "encoded space", false, "/users/a%20b", "/users/a b", map[string]string{"name": "a b"}},
+		{2, "encoded static segment", false, "/%75sers/a", "/users/a", map[string]string{"name": "a"}},
+		{3, "invalid encoding", false, "/users/a%zz", "/users/a%zz", map[string]string{"name": "a%zz"}},
+		{4, "raw path", true, "/users/a%2Fb", "/users/a%2Fb", map[string]string{"name": "a/b"}},
+	}
+
+	for _, tc := range testCases {
+		var v1, v2, functionURL events.APIGatewayProxyRequest
+		newRouter := func(got *events.APIGatewayProxyRequest) *LambdaMux {
+			router := NewLambdaMux()
+			router.UseRawPath(tc.useRawPath)
+			router.GET("/users/:name", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				*got = req
+				return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+			})
+			return router
+		}
+
+		_, err := newRouter(&v2).HandleV2(context.Background(), newV2Request("GET", tc.rawPath))
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		_, err = newRouter(&functionURL).HandleFunctionURL(context.Background(), newFunctionURLRequest("GET", tc.rawPath))
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		_, err = newRouter(&v1).Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.expectedPath})
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+
+		assert.Equal(t, tc.expectedPath, v2.Path, "Test case %d: %s - HTTP API path mismatch", tc.id, tc.name)
+		assert.Equal(t, tc.expectedParams, v2.PathParameters, "Test case %d: %s - HTTP API params mismatch", tc.id, tc.name)
+		assert.Equal(t, tc.expectedPath, functionURL.Path, "Test case %d: %s - Function URL path mismatch", tc.id, tc.name)
+		assert.Equal(t, tc.expectedParams, functionURL.PathParameters, "Test case %d: %s - Function URL params mismatch", tc.id, tc.name)
+		// The same route gives the same params through Handle
+		assert.Equal(t, v1.PathParameters, v2.PathParameters, "Test case %d: %s - Params differ from Handle", tc.id, tc.name)
+	}
+}
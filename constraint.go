@@ -0,0 +1,12 @@
+package lambdamux
+
+import (
+	"github.com/D-Andreev/lambdamux/internal/radix"
+)
+
+// RegisterConstraint registers a named param constraint for use in route patterns, e.g. :id<even>.
+// The int, uuid, alpha and alnum constraints are built in. Constraints are shared by all routers
+// and must be registered before the routes that use them.
+func RegisterConstraint(name string, matcher func(segment string) bool) {
+	radix.RegisterConstraint(name, matcher)
+}
@@ -0,0 +1,63 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterConstraints(t *testing.T) {
+	RegisterConstraint("sku", func(segment string) bool {
+		return strings.HasPrefix(segment, "SKU-")
+	})
+
+	router := NewLambdaMux()
+	router.GET("/orders/:id<int>", createHandler("GET", "/orders/:id<int>"))
+	router.GET("/orders/{ref<uuid>}", createHandler("GET", "/orders/{ref<uuid>}"))
+	router.GET("/orders/:slug", createHandler("GET", "/orders/:slug"))
+	router.GET("/files/:name<[a-z0-9-]+>", createHandler("GET", "/files/:name<[a-z0-9-]+>"))
+	router.GET("/products/:sku<sku>", createHandler("GET", "/products/:sku<sku>"))
+	router.GET("/users/:id<number>", createHandler("GET", "/users/:id<number>"))
+
+	testCases := []struct {
+		id              int
+		name            string
+		path            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{1, "int constraint", "/orders/42", 200, "Handled GET request for /orders/:id<int>"},
+		{2, "uuid constraint", "/orders/3f2c1d6e-8a4b-4c2e-9f1a-0b7d5e6c4a21", 200, "Handled GET request for /orders/{ref<uuid>}"},
+		{3, "fall through to unconstrained param", "/orders/latest", 200, "Handled GET request for /orders/:slug"},
+		{4, "regex constraint", "/files/report-2024", 200, "Handled GET request for /files/:name<[a-z0-9-]+>"},
+		{5, "regex constraint mismatch", "/files/Report.pdf", 404, ""},
+		{6, "custom constraint", "/products/SKU-1", 200, "Handled GET request for /products/:sku<sku>"},
+		{7, "custom constraint mismatch", "/products/1", 404, ""},
+		{8, "unknown constraint", "/users/1", 404, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path})
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				var bodyMap map[string]interface{}
+				err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+				assert.NoError(t, err, "Test case %d: %s - Failed to unmarshal response body", tc.id, tc.name)
+				assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d: %s - Message mismatch", tc.id, tc.name)
+			}
+		})
+	}
+
+	err := router.Validate()
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+	assert.EqualError(t, err, `lambdamux: 1 invalid route(s):
+GET /users/:id<number>: invalid route pattern: unknown constraint number`)
+}
@@ -28,6 +28,8 @@ func main() {
 	// DELETE request with path parameter
 	router.DELETE("/users/:id", deleteUser)
 
+	router.MustCompile()
+
 	lambda.Start(router.Handle)
 }
 
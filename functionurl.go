@@ -0,0 +1,133 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandleFunctionURL processes a Lambda Function URL request using the buffered response mode
+func (r *LambdaMux) HandleFunctionURL(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	resp, err := r.Handle(ctx, r.decodeRawPath(functionURLToProxyRequest(req)))
+	if err != nil {
+		return events.LambdaFunctionURLResponse{}, err
+	}
+
+	return proxyToFunctionURLResponse(resp), nil
+}
+
+// HandleFunctionURLStreaming processes a Lambda Function URL request using the response streaming mode.
+// Handlers registered with Stream write their body directly to the client; the response of any other
+// handler is sent as a single chunk. The status code and headers of a streamed response are those
+// returned through the middleware, so headers added by middleware, e.g. for CORS, are sent.
+func (r *LambdaMux) HandleFunctionURLStreaming(
+	ctx context.Context,
+	req events.LambdaFunctionURLRequest,
+) (*events.LambdaFunctionURLStreamingResponse, error) {
+	pr, pw := io.Pipe()
+	sink := &streamSink{pw: pw}
+	resp, err := r.Handle(context.WithValue(ctx, streamSinkKey{}, sink), r.decodeRawPath(functionURLToProxyRequest(req)))
+	if !sink.streamed {
+		_ = pr.Close()
+		if err != nil {
+			return nil, err
+		}
+		return proxyToFunctionURLStreamingResponse(resp)
+	}
+	if err != nil {
+		// Stops the streaming handler, which fails to write the rest of the body
+		_ = pr.CloseWithError(err)
+		return nil, err
+	}
+
+	headers, cookies := flattenHeaders(resp)
+	return &events.LambdaFunctionURLStreamingResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       pr,
+		Cookies:    cookies,
+	}, nil
+}
+
+// functionURLToProxyRequest converts a Function URL request to the REST API format used by handlers
+func functionURLToProxyRequest(req events.LambdaFunctionURLRequest) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Path:                            req.RawPath,
+		HTTPMethod:                      req.RequestContext.HTTP.Method,
+		Headers:                         headersWithCookies(req.Headers, req.Cookies),
+		MultiValueHeaders:               splitHeaderValues(req.Headers, req.Cookies),
+		QueryStringParameters:           req.QueryStringParameters,
+		MultiValueQueryStringParameters: multiValueQuery(req.RawQueryString),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:    req.RequestContext.AccountID,
+			DomainName:   req.RequestContext.DomainName,
+			DomainPrefix: req.RequestContext.DomainPrefix,
+			RequestID:    req.RequestContext.RequestID,
+			Protocol:     req.RequestContext.HTTP.Protocol,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  req.RequestContext.HTTP.SourceIP,
+				UserAgent: req.RequestContext.HTTP.UserAgent,
+			},
+			Path:             req.RequestContext.HTTP.Path,
+			HTTPMethod:       req.RequestContext.HTTP.Method,
+			RequestTime:      req.RequestContext.Time,
+			RequestTimeEpoch: req.RequestContext.TimeEpoch,
+			APIID:            req.RequestContext.APIID,
+		},
+		Body:            req.Body,
+		IsBase64Encoded: req.IsBase64Encoded,
+	}
+}
+
+// proxyToFunctionURLResponse converts a handler response to the Function URL format
+func proxyToFunctionURLResponse(resp events.APIGatewayProxyResponse) events.LambdaFunctionURLResponse {
+	headers, cookies := flattenHeaders(resp)
+	return events.LambdaFunctionURLResponse{
+		StatusCode:      resp.StatusCode,
+		Headers:         headers,
+		Body:            resp.Body,
+		IsBase64Encoded: resp.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
+
+// proxyToFunctionURLStreamingResponse converts a buffered handler response to a streaming response
+func proxyToFunctionURLStreamingResponse(resp events.APIGatewayProxyResponse) (*events.LambdaFunctionURLStreamingResponse, error) {
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	headers, cookies := flattenHeaders(resp)
+	return &events.LambdaFunctionURLStreamingResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    headers,
+		Body:       bytes.NewReader(body),
+		Cookies:    cookies,
+	}, nil
+}
+
+// flattenHeaders merges the response headers into a single-value map, moving any Set-Cookie headers to cookies
+func flattenHeaders(resp events.APIGatewayProxyResponse) (map[string]string, []string) {
+	headers, multiValueHeaders, cookies := extractCookies(resp.Headers, resp.MultiValueHeaders)
+	if len(multiValueHeaders) == 0 {
+		return headers, cookies
+	}
+
+	result := make(map[string]string, len(headers)+len(multiValueHeaders))
+	for k, v := range multiValueHeaders {
+		result[k] = strings.Join(v, ",")
+	}
+	for k, v := range headers {
+		result[k] = v
+	}
+	return result, cookies
+}
@@ -0,0 +1,203 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFunctionURLRequest(method, path string) events.LambdaFunctionURLRequest {
+	return events.LambdaFunctionURLRequest{
+		Version: "2.0",
+		RawPath: path,
+		RequestContext: events.LambdaFunctionURLRequestContext{
+			HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{
+				Method: method,
+				Path:   path,
+			},
+		},
+	}
+}
+
+func TestHandleFunctionURL(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+
+	var got events.APIGatewayProxyRequest
+	router.POST("/login", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = req
+		return events.APIGatewayProxyResponse{
+			StatusCode:        200,
+			Headers:           map[string]string{"Set-Cookie": "session=abc"},
+			MultiValueHeaders: map[string][]string{"Vary": {"Accept", "Origin"}},
+			Body:              "ok",
+		}, nil
+	})
+
+	resp, err := router.HandleFunctionURL(context.Background(), newFunctionURLRequest("GET", "/pet/123"))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.JSONEq(t, `{"message":"Handled GET request for /pet/:petId","params":{"petId":"123"}}`, resp.Body)
+
+	req := newFunctionURLRequest("POST", "/login")
+	req.Cookies = []string{"theme=dark"}
+	req.RawQueryString = "next=%2Fhome"
+	resp, err = router.HandleFunctionURL(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "theme=dark", got.Headers["cookie"])
+	assert.Equal(t, []string{"/home"}, got.MultiValueQueryStringParameters["next"])
+	assert.Equal(t, []string{"session=abc"}, resp.Cookies)
+	assert.Equal(t, map[string]string{"Vary": "Accept,Origin"}, resp.Headers)
+
+	resp, err = router.HandleFunctionURL(context.Background(), newFunctionURLRequest("GET", "/nonexistent"))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestHandleFunctionURLStreaming(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+	router.GET("/export", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		w.Header()["Content-Type"] = "text/csv"
+		w.Header()["Set-Cookie"] = "export=1"
+		w.WriteHeader(202)
+		for i := 0; i < 3; i++ {
+			if _, err := fmt.Fprintf(w, "row %d\n", i); err != nil {
+				return err
+			}
+		}
+		w.Header()["X-Ignored"] = "true"
+		return nil
+	}))
+	router.GET("/empty", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		w.WriteHeader(204)
+		return nil
+	}))
+	router.GET("/fail-early", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		return errors.New("fail early")
+	}))
+	router.GET("/panic-early", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		panic("boom")
+	}))
+	router.GET("/panic-late", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	}))
+	router.GET("/fail-late", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		_, _ = w.Write([]byte("partial"))
+		return errors.New("fail late")
+	}))
+
+	t.Run("streaming handler", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/export"))
+		assert.NoError(t, err)
+		assert.Equal(t, 202, resp.StatusCode)
+		assert.Equal(t, map[string]string{"Content-Type": "text/csv"}, resp.Headers)
+		assert.Equal(t, []string{"export=1"}, resp.Cookies)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "row 0\nrow 1\nrow 2\n", string(body))
+	})
+
+	t.Run("streaming handler without body", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/empty"))
+		assert.NoError(t, err)
+		assert.Equal(t, 204, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Empty(t, body)
+	})
+
+	t.Run("buffered handler", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/pet/123"))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Headers["Content-Type"])
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"message":"Handled GET request for /pet/:petId","params":{"petId":"123"}}`, string(body))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/nonexistent"))
+		assert.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("error before the first write", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/fail-early"))
+		assert.EqualError(t, err, "fail early")
+		assert.Nil(t, resp)
+	})
+
+	t.Run("error after the first write", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/fail-late"))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.EqualError(t, err, "fail late")
+		assert.Equal(t, "partial", string(body))
+	})
+
+	t.Run("panic before the first write", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/panic-early"))
+		assert.EqualError(t, err, "lambdamux: handler panicked: boom")
+		assert.Nil(t, resp)
+	})
+
+	t.Run("HEAD request served by a streaming GET route", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("HEAD", "/export"))
+		assert.NoError(t, err)
+		assert.Equal(t, 202, resp.StatusCode)
+		assert.Equal(t, map[string]string{"Content-Type": "text/csv"}, resp.Headers)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Empty(t, body)
+	})
+
+	t.Run("panic after the first write", func(t *testing.T) {
+		resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/panic-late"))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.EqualError(t, err, "lambdamux: handler panicked: boom")
+		assert.Equal(t, "partial", string(body))
+	})
+}
+
+func TestHandleFunctionURLStreamingMiddleware(t *testing.T) {
+	router := NewLambdaMux()
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			resp, err := next(ctx, req)
+			if resp.Headers == nil {
+				resp.Headers = map[string]string{}
+			}
+			resp.Headers["Access-Control-Allow-Origin"] = "*"
+			return resp, err
+		}
+	})
+	release := make(chan struct{})
+	router.GET("/export", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		w.Header()["Content-Type"] = "text/csv"
+		_, _ = w.Write([]byte("row 0\n"))
+		// The response is returned through the middleware while the handler is still writing
+		<-release
+		_, _ = w.Write([]byte("row 1\n"))
+		return nil
+	}))
+
+	resp, err := router.HandleFunctionURLStreaming(context.Background(), newFunctionURLRequest("GET", "/export"))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, map[string]string{"Access-Control-Allow-Origin": "*", "Content-Type": "text/csv"}, resp.Headers)
+	close(release)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "row 0\nrow 1\n", string(body))
+}
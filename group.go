@@ -0,0 +1,133 @@
+package lambdamux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group registers routes under a common path prefix with shared middleware.
+// Routes registered on a group are inserted into the router's tree like any other route.
+type Group struct {
+	mux         *LambdaMux
+	prefix      string
+	middlewares []MiddlewareFunc
+	err         error // set if the prefix of the group or of a parent group is invalid
+}
+
+// Group creates a group of routes under prefix and calls fn to register them
+func (r *LambdaMux) Group(prefix string, fn func(g *Group)) *Group {
+	g := r.Route(prefix)
+	fn(g)
+	return g
+}
+
+// Route returns a group for registering routes under prefix
+func (r *LambdaMux) Route(prefix string) *Group {
+	return &Group{mux: r, prefix: prefix, err: pathError("group prefix", prefix)}
+}
+
+// Group creates a nested group under prefix, inheriting the group's middleware, and calls fn to register its routes
+func (g *Group) Group(prefix string, fn func(g *Group)) *Group {
+	child := g.Route(prefix)
+	fn(child)
+	return child
+}
+
+// Route returns a nested group under prefix, inheriting the group's middleware
+func (g *Group) Route(prefix string) *Group {
+	err := g.err
+	if err == nil {
+		err = pathError("group prefix", prefix)
+	}
+	return &Group{
+		mux:         g.mux,
+		prefix:      joinPaths(g.prefix, prefix),
+		middlewares: append([]MiddlewareFunc(nil), g.middlewares...),
+		err:         err,
+	}
+}
+
+// Use appends middleware to the group. It applies to routes registered on the group and its nested
+// groups afterwards, running after the router middleware and before any route middleware.
+func (g *Group) Use(middlewares ...MiddlewareFunc) {
+	g.mux.checkMutable()
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+func (g *Group) addRoute(method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) *Route {
+	all := make([]MiddlewareFunc, 0, len(g.middlewares)+len(middlewares))
+	all = append(all, g.middlewares...)
+	all = append(all, middlewares...)
+	err := g.err
+	if err == nil {
+		err = pathError("path", path)
+	}
+	if err != nil {
+		// Joining the paths would hide the error, e.g. /v1 and users would make /v1users
+		rt := g.mux.newRoute(method, joinPaths(g.prefix, path), handler, all)
+		rt.err = err
+		return rt
+	}
+	return g.mux.addRoute(method, joinPaths(g.prefix, path), handler, all...)
+}
+
+// pathError returns ErrInvalidPattern if path isn't empty and doesn't begin with /
+func pathError(name, path string) error {
+	if path == "" || strings.HasPrefix(path, "/") {
+		return nil
+	}
+	return fmt.Errorf("%w: %s %s must begin with /", ErrInvalidPattern, name, path)
+}
+
+// Method registers a new route for an arbitrary HTTP method with the given path, handler and route middleware
+func (g *Group) Method(method, path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute(strings.ToUpper(method), path, handler, middlewares)
+}
+
+// GET registers a new GET route with the given path, handler and route middleware
+func (g *Group) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("GET", path, handler, middlewares)
+}
+
+// POST registers a new POST route with the given path, handler and route middleware
+func (g *Group) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("POST", path, handler, middlewares)
+}
+
+// PUT registers a new PUT route with the given path, handler and route middleware
+func (g *Group) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("PUT", path, handler, middlewares)
+}
+
+// DELETE registers a new DELETE route with the given path, handler and route middleware
+func (g *Group) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("DELETE", path, handler, middlewares)
+}
+
+// PATCH registers a new PATCH route with the given path, handler and route middleware
+func (g *Group) PATCH(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("PATCH", path, handler, middlewares)
+}
+
+// HEAD registers a new HEAD route with the given path, handler and route middleware
+func (g *Group) HEAD(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("HEAD", path, handler, middlewares)
+}
+
+// OPTIONS registers a new OPTIONS route with the given path, handler and route middleware
+func (g *Group) OPTIONS(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute("OPTIONS", path, handler, middlewares)
+}
+
+// ANY registers a new route matching every HTTP method with the given path, handler and route middleware
+func (g *Group) ANY(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.addRoute(anyMethod, path, handler, middlewares)
+}
+
+// joinPaths appends path to prefix, dropping a trailing slash from prefix
+func joinPaths(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
@@ -0,0 +1,182 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	router := NewLambdaMux()
+	router.Use(recordingMiddleware("router", &calls))
+	router.GET("/health", createHandler("GET", "/health"))
+
+	router.Group("/v1", func(v1 *Group) {
+		v1.GET("/pets", createHandler("GET", "/v1/pets"))
+
+		v1.Group("/admin/", func(admin *Group) {
+			admin.Use(recordingMiddleware("admin", &calls))
+			admin.GET("/users", createHandler("GET", "/v1/admin/users"))
+			admin.DELETE("/users/:id", createHandler("DELETE", "/v1/admin/users/:id"), recordingMiddleware("route", &calls))
+
+			audit := admin.Route("/audit")
+			audit.Use(recordingMiddleware("audit", &calls))
+			audit.GET("", createHandler("GET", "/v1/admin/audit"))
+			audit.GET("/:eventId", createHandler("GET", "/v1/admin/audit/:eventId"))
+		})
+
+		v1.POST("/pets", createHandler("POST", "/v1/pets"))
+	})
+
+	testCases := []struct {
+		id              int
+		name            string
+		method          string
+		path            string
+		expectedStatus  int
+		expectedMessage string
+		expectedParams  map[string]interface{}
+		expectedCalls   []string
+	}{
+		{1, "router route", "GET", "/health", 200, "Handled GET request for /health", nil, []string{"router"}},
+		{2, "group route", "GET", "/v1/pets", 200, "Handled GET request for /v1/pets", nil, []string{"router"}},
+		{
+			3,
+			"group route registered after a nested group",
+			"POST",
+			"/v1/pets",
+			200,
+			"Handled POST request for /v1/pets",
+			nil,
+			[]string{"router"},
+		},
+		{
+			4,
+			"nested group route",
+			"GET",
+			"/v1/admin/users",
+			200,
+			"Handled GET request for /v1/admin/users",
+			nil,
+			[]string{"router", "admin"},
+		},
+		{
+			5,
+			"nested group route with route middleware",
+			"DELETE",
+			"/v1/admin/users/42",
+			200,
+			"Handled DELETE request for /v1/admin/users/:id",
+			map[string]interface{}{"id": "42"},
+			[]string{"router", "admin", "route"},
+		},
+		{
+			6,
+			"group prefix route",
+			"GET",
+			"/v1/admin/audit",
+			200,
+			"Handled GET request for /v1/admin/audit",
+			nil,
+			[]string{"router", "admin", "audit"},
+		},
+		{
+			7,
+			"group param route",
+			"GET",
+			"/v1/admin/audit/7",
+			200,
+			"Handled GET request for /v1/admin/audit/:eventId",
+			map[string]interface{}{"eventId": "7"},
+			[]string{"router", "admin", "audit"},
+		},
+		{8, "unprefixed group route", "GET", "/pets", 404, "", nil, []string{"router"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			calls = nil
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+
+			var before []string
+			for i := 0; i < len(calls)/2; i++ {
+				before = append(before, calls[i][len("before "):])
+			}
+			assert.Equal(t, tc.expectedCalls, before, "Test case %d: %s - Middleware mismatch", tc.id, tc.name)
+
+			if tc.expectedStatus != 200 {
+				return
+			}
+			var bodyMap map[string]interface{}
+			err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+			assert.NoError(t, err, "Test case %d: %s - Failed to unmarshal response body", tc.id, tc.name)
+			assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d: %s - Message mismatch", tc.id, tc.name)
+			if tc.expectedParams != nil {
+				assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d: %s - Params mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestGroupMiddlewareIsolation(t *testing.T) {
+	var calls []string
+	router := NewLambdaMux()
+
+	parent := router.Route("/api")
+	parent.Use(recordingMiddleware("parent", &calls))
+	child := parent.Route("/child")
+	child.Use(recordingMiddleware("child", &calls))
+	parent.GET("/sibling", createHandler("GET", "/api/sibling"))
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/api/sibling"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, []string{"before parent", "after parent"}, calls)
+}
+
+func TestGroupInvalidPaths(t *testing.T) {
+	testCases := []struct {
+		id       int
+		name     string
+		register func(r *LambdaMux)
+		expected string
+	}{
+		{1, "route path", func(r *LambdaMux) {
+			r.Group("/v1", func(g *Group) { g.GET("users", createHandler("GET", "/v1/users")) })
+		}, "GET /v1users: invalid route pattern: path users must begin with /"},
+		{2, "group prefix", func(r *LambdaMux) {
+			r.Route("v1").GET("/users", createHandler("GET", "/v1/users"))
+		}, "GET v1/users: invalid route pattern: group prefix v1 must begin with /"},
+		{3, "nested group prefix", func(r *LambdaMux) {
+			r.Group("/v1", func(g *Group) {
+				g.Group("admin", func(admin *Group) { admin.GET("/users", createHandler("GET", "/v1/admin/users")) })
+			})
+		}, "GET /v1admin/users: invalid route pattern: group prefix admin must begin with /"},
+		{4, "route of a group nested in an invalid group", func(r *LambdaMux) {
+			r.Route("/v1").Route("admin").Route("/audit").GET("/:eventId", createHandler("GET", "/v1/admin/audit/:eventId"))
+		}, "GET /v1admin/audit/:eventId: invalid route pattern: group prefix admin must begin with /"},
+	}
+
+	for _, tc := range testCases {
+		router := NewLambdaMux()
+		tc.register(router)
+
+		err := router.Validate()
+		assert.ErrorIs(t, err, ErrInvalidPattern, "Test case %d: %s - Expected an invalid pattern", tc.id, tc.name)
+		assert.ErrorContains(t, err, tc.expected, "Test case %d: %s - Error mismatch", tc.id, tc.name)
+		assert.Empty(t, router.Routes(), "Test case %d: %s - Invalid route was registered", tc.id, tc.name)
+	}
+}
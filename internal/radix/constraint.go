@@ -0,0 +1,103 @@
+package radix
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// MatcherFunc reports whether a path segment satisfies a param constraint
+type MatcherFunc func(string) bool
+
+var (
+	constraintsMu sync.RWMutex
+	constraints   = map[string]MatcherFunc{
+		"int":   isInt,
+		"uuid":  isUUID,
+		"alpha": isAlpha,
+		"alnum": isAlnum,
+	}
+	constraintNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+// RegisterConstraint registers a named constraint, replacing any constraint with the same name
+func RegisterConstraint(name string, matcher MatcherFunc) {
+	constraintsMu.Lock()
+	defer constraintsMu.Unlock()
+	constraints[name] = matcher
+}
+
+// newMatcher returns the matcher for a constraint. A constraint is either the name of a registered
+// constraint or a regular expression that must match the whole segment.
+func newMatcher(constraint string) (MatcherFunc, error) {
+	constraintsMu.RLock()
+	matcher, ok := constraints[constraint]
+	constraintsMu.RUnlock()
+	if ok {
+		return matcher, nil
+	}
+	if constraintNameRe.MatchString(constraint) {
+		return nil, fmt.Errorf("%w: unknown constraint %s", ErrInvalidPattern, constraint)
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%w: constraint %s: %v", ErrInvalidPattern, constraint, err)
+	}
+	return re.MatchString, nil
+}
+
+// isInt reports whether s is a decimal integer with an optional leading minus sign
+func isInt(s string) bool {
+	if len(s) > 1 && s[0] == '-' {
+		s = s[1:]
+	}
+	return len(s) > 0 && allBytes(s, isDigit)
+}
+
+// isUUID reports whether s is a UUID in its canonical 8-4-4-4-12 hex form
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if s[i] != '-' {
+				return false
+			}
+		} else if !isHex(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlpha reports whether s consists of ASCII letters only
+func isAlpha(s string) bool {
+	return len(s) > 0 && allBytes(s, isLetter)
+}
+
+// isAlnum reports whether s consists of ASCII letters and digits only
+func isAlnum(s string) bool {
+	return len(s) > 0 && allBytes(s, func(c byte) bool { return isLetter(c) || isDigit(c) })
+}
+
+func allBytes(s string, fn func(byte) bool) bool {
+	for i := 0; i < len(s); i++ {
+		if !fn(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
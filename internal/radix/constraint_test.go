@@ -0,0 +1,65 @@
+package radix
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinConstraints(t *testing.T) {
+	testCases := []struct {
+		id         int
+		constraint string
+		input      string
+		expected   bool
+	}{
+		{1, "int", "42", true},
+		{2, "int", "-42", true},
+		{3, "int", "-", false},
+		{4, "int", "4a", false},
+		{5, "int", "", false},
+		{6, "uuid", "3f2c1d6e-8a4b-4c2e-9f1a-0b7d5e6c4a21", true},
+		{7, "uuid", "3F2C1D6E-8A4B-4C2E-9F1A-0B7D5E6C4A21", true},
+		{8, "uuid", "3f2c1d6e8a4b4c2e9f1a0b7d5e6c4a21", false},
+		{9, "uuid", "3f2c1d6e-8a4b-4c2e-9f1a-0b7d5e6c4a2g", false},
+		{10, "alpha", "abcXYZ", true},
+		{11, "alpha", "abc1", false},
+		{12, "alnum", "abc123", true},
+		{13, "alnum", "abc-123", false},
+		{14, "[a-z0-9-]+", "my-file-1", true},
+		{15, "[a-z0-9-]+", "My-file", false},
+		{16, "[a-z]{2}", "abc", false},
+	}
+
+	for _, tc := range testCases {
+		matcher, err := newMatcher(tc.constraint)
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Equal(t, tc.expected, matcher(tc.input), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	_, err := newMatcher("integer")
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+	assert.EqualError(t, err, "invalid route pattern: unknown constraint integer")
+
+	_, err = newMatcher("[a-z")
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+}
+
+func TestRegisterConstraint(t *testing.T) {
+	RegisterConstraint("even", func(s string) bool {
+		return isInt(s) && (s[len(s)-1]-'0')%2 == 0
+	})
+
+	tree := NewNode("", false)
+	_, err := tree.Insert("/numbers/:n<even>")
+	assert.NoError(t, err)
+
+	result, params := tree.Search("/numbers/12")
+	assert.NotNil(t, result)
+	assert.Equal(t, map[string]string{"n": "12"}, params)
+	result, _ = tree.Search("/numbers/13")
+	assert.Nil(t, result)
+}
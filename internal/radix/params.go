@@ -0,0 +1,20 @@
+package radix
+
+// Param is the value of a param or wildcard matched by a search
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params holds the params matched by a search. The params of a pattern are appended from the last
+// to the first, as they are recorded once the search has found a complete match.
+type Params []Param
+
+// Map returns the params as a new map, which is empty if there are none
+func (ps Params) Map() map[string]string {
+	m := make(map[string]string, len(ps))
+	for _, p := range ps {
+		m[p.Key] = p.Value
+	}
+	return m
+}
@@ -0,0 +1,49 @@
+package radix
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	tree := NewNode("", false)
+	for _, pattern := range []string{"/users", "/users/:id", "/users/:id/orders/:orderId", "/static/*filepath"} {
+		_, err := tree.Insert(pattern)
+		assert.NoError(t, err)
+	}
+
+	testCases := []struct {
+		id       int
+		path     string
+		fold     bool
+		expected string
+		params   Params
+	}{
+		{1, "/users", false, "/users", nil},
+		{2, "/users/42/orders/7", false, "/users/:id/orders/:orderId", Params{{"orderId", "7"}, {"id", "42"}}},
+		{3, "/static/css/app.css", false, "/static/*filepath", Params{{"filepath", "css/app.css"}}},
+		{4, "/USERS/Bob", true, "/users/:id", Params{{"id", "Bob"}}},
+		{5, "/USERS/Bob", false, "", nil},
+		{6, "/orders", false, "", nil},
+	}
+
+	for _, tc := range testCases {
+		var params Params
+		node := tree.Lookup(tc.path, &params, tc.fold)
+		if tc.expected == "" {
+			assert.Nil(t, node, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		} else if assert.NotNil(t, node, fmt.Sprintf("Failed test id: %d\n", tc.id)) {
+			assert.Equal(t, tc.expected, node.fullValue, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		}
+		assert.Equal(t, tc.params, params, fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestParamsMap(t *testing.T) {
+	params := Params{{"orderId", "7"}, {"id", "42"}}
+
+	assert.Equal(t, map[string]string{"id": "42", "orderId": "7"}, params.Map())
+	assert.Equal(t, map[string]string{}, Params{}.Map())
+}
@@ -0,0 +1,270 @@
+package radix
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	staticToken tokenKind = iota
+	paramToken
+	wildcardToken
+)
+
+// token is a static, param or wildcard part of a route pattern
+type token struct {
+	kind  tokenKind
+	value string // the static text, or the param or wildcard including its prefix and constraint, e.g. :id<int>
+	start int    // the index of the token in the pattern
+}
+
+// tokenize splits a normalized pattern into static, param and wildcard tokens. A param starts with
+// a colon at the beginning of a segment or after a character other than a letter, digit or
+// underscore, so that a segment can hold several params separated by literals, e.g. :name.:ext.
+// Its name is made of letters, digits and underscores and may be followed by a constraint in
+// angle brackets. A wildcard starts with an asterisk at the beginning of the last segment.
+func tokenize(pattern string) ([]token, error) {
+	var tokens []token
+	names := map[string]bool{}
+	start := 0
+	for i := 0; i < len(pattern); {
+		switch {
+		case pattern[i] == '*' && i > 0 && pattern[i-1] == '/':
+			segment := segmentAt(pattern, i)
+			name := pattern[i+1:]
+			switch {
+			case strings.IndexByte(name, '/') >= 0:
+				return nil, fmt.Errorf("%w: wildcard %s must be the last segment", ErrInvalidPattern, segment)
+			case name == "":
+				return nil, fmt.Errorf("%w: unnamed param in segment %d", ErrInvalidPattern, segmentIdx(pattern, i))
+			case strings.IndexByte(name, '<') >= 0:
+				return nil, fmt.Errorf("%w: wildcard %s can't have a constraint", ErrInvalidPattern, segment)
+			case names[name]:
+				return nil, fmt.Errorf("%w: duplicate param name %s", ErrInvalidPattern, name)
+			}
+			tokens = appendStatic(tokens, pattern, start, i)
+			tokens = append(tokens, token{kind: wildcardToken, value: pattern[i:], start: i})
+			return tokens, checkStatic(tokens)
+		case isParamStart(pattern, i):
+			end, err := paramEnd(pattern, i)
+			if err != nil {
+				return nil, err
+			}
+			name, _ := splitParam(pattern[i:end])
+			if names[name] {
+				return nil, fmt.Errorf("%w: duplicate param name %s", ErrInvalidPattern, name)
+			}
+			names[name] = true
+			if end < len(pattern) && pattern[end] == '?' {
+				return nil, fmt.Errorf(
+					"%w: optional param %s must be a trailing segment", ErrInvalidPattern, segmentAt(pattern, i),
+				)
+			}
+			if end < len(pattern) && pattern[end] == ':' {
+				return nil, fmt.Errorf(
+					"%w: params in segment %s must be separated by a literal", ErrInvalidPattern, segmentAt(pattern, i),
+				)
+			}
+			tokens = appendStatic(tokens, pattern, start, i)
+			tokens = append(tokens, token{kind: paramToken, value: pattern[i:end], start: i})
+			i, start = end, end
+		default:
+			i++
+		}
+	}
+	tokens = appendStatic(tokens, pattern, start, len(pattern))
+	return tokens, checkStatic(tokens)
+}
+
+// Expand returns the normalized patterns inserted by InsertWithHandler for input, one for each
+// combination of trailing optional params
+func Expand(input string) []string {
+	return expandOptional(normalizePattern(input))
+}
+
+// expandOptional returns the patterns matched by a pattern ending in optional params, e.g.
+// /archive/:year?/:month? expands to /archive, /archive/:year and /archive/:year/:month.
+// Other patterns are returned as is.
+func expandOptional(pattern string) []string {
+	segments := strings.Split(pattern, "/")
+	first := len(segments)
+	for first > 1 && isOptionalSegment(segments[first-1]) {
+		first--
+	}
+	if first == len(segments) {
+		return []string{pattern}
+	}
+
+	for i := first; i < len(segments); i++ {
+		segments[i] = strings.TrimSuffix(segments[i], "?")
+	}
+	patterns := make([]string, 0, len(segments)-first+1)
+	for i := first; i <= len(segments); i++ {
+		p := strings.Join(segments[:i], "/")
+		if p == "" {
+			p = "/"
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// isOptionalSegment reports whether segment is a single param followed by a question mark, e.g. :id? or :id<int>?
+func isOptionalSegment(segment string) bool {
+	if len(segment) < 3 || segment[0] != ':' || segment[len(segment)-1] != '?' {
+		return false
+	}
+	end, err := paramEnd("/"+segment, 1)
+	return err == nil && end == len(segment)
+}
+
+// isParamStart reports whether index i of pattern starts a param
+func isParamStart(pattern string, i int) bool {
+	return pattern[i] == ':' && i > 0 && !isNameByte(pattern[i-1])
+}
+
+// paramEnd validates the param starting at index i of pattern and returns the index after its
+// name and constraint
+func paramEnd(pattern string, i int) (int, error) {
+	end := i + 1
+	for end < len(pattern) && isNameByte(pattern[end]) {
+		end++
+	}
+	if end == i+1 {
+		return 0, fmt.Errorf("%w: unnamed param in segment %d", ErrInvalidPattern, segmentIdx(pattern, i))
+	}
+	if end == len(pattern) || pattern[end] != '<' {
+		return end, nil
+	}
+
+	// The constraint ends at the matching closing bracket, so that it may contain named groups
+	depth := 0
+	for j := end; j < len(pattern) && pattern[j] != '/'; j++ {
+		switch pattern[j] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		}
+		if depth > 0 {
+			continue
+		}
+		constraint := pattern[end+1 : j]
+		if constraint == "" {
+			return 0, fmt.Errorf("%w: empty constraint in segment %s", ErrInvalidPattern, segmentAt(pattern, i))
+		}
+		if _, err := newMatcher(constraint); err != nil {
+			return 0, err
+		}
+		return j + 1, nil
+	}
+	return 0, fmt.Errorf("%w: malformed segment %s", ErrInvalidPattern, segmentAt(pattern, i))
+}
+
+// appendStatic appends pattern[start:end] to tokens as a static token unless it is empty
+func appendStatic(tokens []token, pattern string, start, end int) []token {
+	if start == end {
+		return tokens
+	}
+	return append(tokens, token{kind: staticToken, value: pattern[start:end], start: start})
+}
+
+// checkStatic returns an error if a static token contains a brace left over from a malformed {name} segment
+func checkStatic(tokens []token) error {
+	for _, tok := range tokens {
+		if tok.kind != staticToken || !strings.ContainsAny(tok.value, "{}") {
+			continue
+		}
+		for _, segment := range strings.Split(tok.value, "/") {
+			if strings.ContainsAny(segment, "{}") {
+				return fmt.Errorf("%w: malformed segment %s", ErrInvalidPattern, segment)
+			}
+		}
+	}
+	return nil
+}
+
+// isNameByte reports whether c can be part of a param name
+func isNameByte(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_'
+}
+
+// segmentAt returns the path segment of pattern containing index i
+func segmentAt(pattern string, i int) string {
+	start := strings.LastIndexByte(pattern[:i], '/') + 1
+	end := strings.IndexByte(pattern[i:], '/')
+	if end < 0 {
+		return pattern[start:]
+	}
+	return pattern[start : i+end]
+}
+
+// segmentIdx returns the index of the path segment of pattern containing index i
+func segmentIdx(pattern string, i int) int {
+	return strings.Count(pattern[:i], "/")
+}
+
+// splitParam splits a param such as :id<int> into its name and constraint
+func splitParam(param string) (string, string) {
+	s := param[1:]
+	if i := strings.IndexByte(s, '<'); i >= 0 && strings.HasSuffix(s, ">") {
+		return s[:i], s[i+1 : len(s)-1]
+	}
+	return s, ""
+}
+
+// normalizePattern converts API Gateway style {name} params to :name and a {name+} segment to *name.
+// A {name} is only converted where a :name param could start, so that misplaced braces are reported
+// as malformed segments.
+func normalizePattern(pattern string) string {
+	if !strings.Contains(pattern, "{") {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		// Copy :name params as is, as their constraint may contain braces
+		if isParamStart(pattern, i) {
+			if end, err := paramEnd(pattern, i); err == nil {
+				b.WriteString(pattern[i:end])
+				i = end - 1
+				continue
+			}
+		}
+		end := closingBrace(pattern, i)
+		if pattern[i] != '{' || i == 0 || isNameByte(pattern[i-1]) || end < 0 {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		inner := pattern[i+1 : end]
+		if pattern[i-1] == '/' && strings.HasSuffix(inner, "+") && (end == len(pattern)-1 || pattern[end+1] == '/') {
+			b.WriteString("*" + inner[:len(inner)-1])
+		} else {
+			b.WriteString(":" + inner)
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// closingBrace returns the index of the brace closing the one at index i of pattern within the
+// same segment, or -1 if there is none
+func closingBrace(pattern string, i int) int {
+	if pattern[i] != '{' {
+		return -1
+	}
+	depth := 0
+	for j := i; j < len(pattern) && pattern[j] != '/'; j++ {
+		switch pattern[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package radix
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePattern(t *testing.T) {
+	testCases := []struct {
+		id       int
+		pattern  string
+		expected string
+	}{
+		{1, "/users/:id", "/users/:id"},
+		{2, "/users/{id}", "/users/:id"},
+		{3, "/api/{proxy+}", "/api/*proxy"},
+		{4, "/files/{name}.{ext}", "/files/:name.:ext"},
+		{5, "/reports/{from}-{to}/summary", "/reports/:from-:to/summary"},
+		{6, "/orders/{code<[A-Z]{3}>}", "/orders/:code<[A-Z]{3}>"},
+		{7, "/orders/:code<[A-Z]{3}>", "/orders/:code<[A-Z]{3}>"},
+		{8, "/files/{name+}.txt", "/files/:name+.txt"},
+		{9, "/users/id{id}", "/users/id{id}"},
+		{10, "/users/{id", "/users/{id"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, normalizePattern(tc.pattern), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		id       int
+		pattern  string
+		expected []token
+	}{
+		{1, "/users", []token{{staticToken, "/users", 0}}},
+		{
+			2,
+			"/users/:id/orders",
+			[]token{{staticToken, "/users/", 0}, {paramToken, ":id", 7}, {staticToken, "/orders", 10}},
+		},
+		{
+			3,
+			"/files/:name.:ext",
+			[]token{{staticToken, "/files/", 0}, {paramToken, ":name", 7}, {staticToken, ".", 12}, {paramToken, ":ext", 13}},
+		},
+		{
+			4,
+			"/reports/:from<int>-:to<int>",
+			[]token{
+				{staticToken, "/reports/", 0},
+				{paramToken, ":from<int>", 9},
+				{staticToken, "-", 19},
+				{paramToken, ":to<int>", 20},
+			},
+		},
+		{5, "/files/report-:date", []token{{staticToken, "/files/report-", 0}, {paramToken, ":date", 14}}},
+		{6, "/v1/things:batchGet", []token{{staticToken, "/v1/things:batchGet", 0}}},
+		{7, "/static/*filepath", []token{{staticToken, "/static/", 0}, {wildcardToken, "*filepath", 8}}},
+		{8, "/files/a*b", []token{{staticToken, "/files/a*b", 0}}},
+		{9, "/users/:name<(?P<first>[a-z]+)>", []token{{staticToken, "/users/", 0}, {paramToken, ":name<(?P<first>[a-z]+)>", 7}}},
+	}
+
+	for _, tc := range testCases {
+		tokens, err := tokenize(tc.pattern)
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Equal(t, tc.expected, tokens, fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestExpandOptional(t *testing.T) {
+	testCases := []struct {
+		id       int
+		pattern  string
+		expected []string
+	}{
+		{1, "/items/:id", []string{"/items/:id"}},
+		{2, "/items/:id?", []string{"/items", "/items/:id"}},
+		{3, "/archive/:year<int>?/:month<int>?", []string{"/archive", "/archive/:year<int>", "/archive/:year<int>/:month<int>"}},
+		{4, "/:lang?", []string{"/", "/:lang"}},
+		{5, "/items/:id?/edit", []string{"/items/:id?/edit"}},
+		{6, "/archive/:year?/:month", []string{"/archive/:year?/:month"}},
+		{7, "/files/:name.:ext?", []string{"/files/:name.:ext?"}},
+		{8, "/search/:q<[a-z]?>", []string{"/search/:q<[a-z]?>"}},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, expandOptional(tc.pattern), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
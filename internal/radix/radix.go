@@ -2,7 +2,9 @@ package radix
 
 import (
 	"context"
-	"log/slog"
+	"errors"
+	"fmt"
+	"net/url"
 	"slices"
 	"sort"
 	"strings"
@@ -10,199 +12,479 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
+// HandlerFunc is the handler signature stored for each method of a complete node
+type HandlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+var (
+	// ErrInvalidPattern is returned when a route pattern is malformed
+	ErrInvalidPattern = errors.New("invalid route pattern")
+	// ErrConflict is returned when a route pattern conflicts with an already inserted one
+	ErrConflict = errors.New("conflicting route")
+	// ErrDuplicateRoute is returned when a handler is already registered for the method and pattern
+	ErrDuplicateRoute = errors.New("duplicate route")
+)
+
 type Node struct {
-	edges      []*Node // sorted in ascending order
-	isComplete bool
-	value      string
-	fullValue  string // used only when returning a node from Search method, otherwise it's not populated
-	isParam    bool
-	paramNames []string
-	Handler    func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+	edges        []*Node // static edges, sorted in ascending order
+	paramEdges   []*Node // match a single path segment, constrained params first, e.g. :id<int> then :id
+	wildcardEdge *Node   // matches the rest of the path including slashes, e.g. *filepath
+	isComplete   bool
+	value        string
+	matcher      MatcherFunc            // the constraint of a param node, nil if the param is unconstrained
+	fullValue    string                 // the pattern of the route ending at this node, only populated for complete nodes
+	handlers     map[string]HandlerFunc // keyed by HTTP method
 }
 
 // NewNode creates a new node
 func NewNode(value string, isComplete bool) *Node {
-	isParam := strings.Contains(value, ":")
-	paramNames := getParamNames(value)
-
 	return &Node{
 		edges:      []*Node{},
 		isComplete: isComplete,
 		value:      value,
-		isParam:    isParam,
-		paramNames: paramNames,
-		Handler:    nil,
 	}
 }
 
-// getParamNames returns the names of the parameters in the given value
-func getParamNames(value string) []string {
-	var paramNames []string
-	segments := strings.Split(value, "/")
-	for _, segment := range segments {
-		if strings.HasPrefix(segment, ":") {
-			paramNames = append(paramNames, segment[1:])
+// paramName returns the name of a param or wildcard node
+func (n *Node) paramName() string {
+	name, _ := splitParam(n.value)
+	return name
+}
+
+// InsertWithHandler inserts a new node in the tree and registers a handler for the given method on it.
+// Trailing optional params written as :name? or {name?} are expanded, so that the handler is also
+// registered for the pattern without them. A handler already registered for the method is kept
+// and ErrDuplicateRoute is returned.
+func (n *Node) InsertWithHandler(method, input string, handler HandlerFunc) error {
+	// Every pattern is checked before any is inserted, so that an error leaves the tree unchanged
+	patterns := expandOptional(normalizePattern(input))
+	for _, pattern := range patterns {
+		node, err := n.find(pattern)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			continue
+		}
+		if _, ok := node.handlers[method]; ok {
+			return fmt.Errorf("%w: %s is already registered", ErrDuplicateRoute, node.fullValue)
 		}
 	}
-	return paramNames
+
+	nodes := make([]*Node, 0, len(patterns))
+	for _, pattern := range patterns {
+		node, err := n.Insert(pattern)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes {
+		if node.handlers == nil {
+			node.handlers = map[string]HandlerFunc{}
+		}
+		node.handlers[method] = handler
+	}
+	return nil
 }
 
-// InsertWithHandler inserts a new node in the tree with a handler
-func (n *Node) InsertWithHandler(
-	input string,
-	handler func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error),
-) {
-	node := n.Insert(input)
-	if node == nil {
-		return
+// HasHandlers reports whether a handler is registered for any method
+func (n *Node) HasHandlers() bool {
+	return len(n.handlers) > 0
+}
+
+// Handler returns the handler registered for the given method, or nil if there is none
+func (n *Node) Handler(method string) HandlerFunc {
+	return n.handlers[method]
+}
+
+// Methods returns the methods that have a handler registered, sorted in ascending order
+func (n *Node) Methods() []string {
+	methods := make([]string, 0, len(n.handlers))
+	for method := range n.handlers {
+		methods = append(methods, method)
 	}
-	node.Handler = handler
+	sort.Strings(methods)
+	return methods
 }
 
-// Insert inserts a new node in the tree
-func (n *Node) Insert(input string) *Node {
-	node := n
-	search := input
-	for {
-		// The key is exhausted and the deepest possible node found.
-		if len(search) == 0 {
-			node.isComplete = true
-			return node
+// Path returns the path matching the pattern of a complete node, with its params and wildcard
+// replaced by the given values
+func (n *Node) Path(params map[string]string) string {
+	tokens, _ := tokenize(n.fullValue)
+	var b strings.Builder
+	for _, tok := range tokens {
+		if tok.kind == staticToken {
+			b.WriteString(tok.value)
+			continue
 		}
+		name, _ := splitParam(tok.value)
+		b.WriteString(params[name])
+	}
+	return b.String()
+}
 
-		parent := node
-		node = node.getEdge(search[0], false)
+// Params returns the names of the params and the wildcard in the pattern of a complete node, in
+// the order they appear
+func (n *Node) Params() []string {
+	tokens, _ := tokenize(n.fullValue)
+	var names []string
+	for _, tok := range tokens {
+		if tok.kind != staticToken {
+			name, _ := splitParam(tok.value)
+			names = append(names, name)
+		}
+	}
+	return names
+}
 
-		// No matching edge was found. Just create new edge.
-		if node == nil {
-			node = NewNode(search, true)
-			parent.addEdge(node)
-			return node
+// URL returns the path matching the pattern of a complete node, with its params and wildcard
+// replaced by the given values escaped for use in a URL path. Slashes in a wildcard value are kept.
+// An error is returned if a value is empty or doesn't satisfy the constraint of its param.
+func (n *Node) URL(params map[string]string) (string, error) {
+	tokens, _ := tokenize(n.fullValue)
+	var b strings.Builder
+	for _, tok := range tokens {
+		switch tok.kind {
+		case staticToken:
+			b.WriteString(tok.value)
+		case paramToken:
+			name, constraint := splitParam(tok.value)
+			value := params[name]
+			if value == "" {
+				return "", fmt.Errorf("param %s is empty", name)
+			}
+			if constraint != "" {
+				if matcher, err := newMatcher(constraint); err != nil || !matcher(value) {
+					return "", fmt.Errorf("param %s: value %q doesn't satisfy constraint %s", name, value, constraint)
+				}
+			}
+			b.WriteString(url.PathEscape(value))
+		case wildcardToken:
+			segments := strings.Split(params[tok.value[1:]], "/")
+			for i, segment := range segments {
+				segments[i] = url.PathEscape(segment)
+			}
+			b.WriteString(strings.Join(segments, "/"))
 		}
+	}
+	return b.String(), nil
+}
 
-		commonPrefix := getCommonPrefix(search, node.value)
+// Insert inserts a new node in the tree. Params written as :name or {name} are inserted as param
+// nodes and a trailing segment written as *name or {name+} is inserted as a wildcard node.
+// A segment may hold several params separated by literals, e.g. :name.:ext or :from-:to.
+// A param may be followed by a constraint in angle brackets, either the name of a registered
+// constraint or a regular expression, e.g. :id<int> or :name<[a-z0-9-]+>.
+// Optional params are expanded by InsertWithHandler. An error is returned if the pattern is
+// malformed or conflicts with an already inserted pattern.
+func (n *Node) Insert(input string) (*Node, error) {
+	pattern := normalizePattern(input)
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return nil, err
+	}
+	node := n
+	for _, tok := range tokens {
+		switch tok.kind {
+		case paramToken:
+			child, err := node.insertParam(tok.value, pattern[:tok.start])
+			if err != nil {
+				return nil, err
+			}
+			node = child
+		case wildcardToken:
+			if err := node.checkWildcard(tok.value, pattern[:tok.start]); err != nil {
+				return nil, err
+			}
+			if node.wildcardEdge == nil {
+				node.wildcardEdge = NewNode(tok.value, false)
+			}
+			node = node.wildcardEdge
+		default:
+			node = node.insertStatic(tok.value)
+		}
+	}
 
-		// The current node's value is a fullValue of the search string. Go deeper with remainder.
-		if commonPrefix == len(node.value) {
-			search = search[commonPrefix:]
-			continue
+	node.isComplete = true
+	node.fullValue = pattern
+	return node, nil
+}
+
+// Check returns the error Insert would return for input without changing the tree
+func (n *Node) Check(input string) error {
+	_, err := n.find(normalizePattern(input))
+	return err
+}
+
+// find returns the node of a normalized pattern if it is already in the tree, or nil if inserting
+// it would add nodes. An error is returned if the pattern is malformed or conflicts with an already
+// inserted pattern. The tree is not changed.
+func (n *Node) find(pattern string) (*Node, error) {
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return nil, err
+	}
+	node := n
+	for _, tok := range tokens {
+		switch tok.kind {
+		case paramToken:
+			node, err = node.findParam(tok.value, pattern[:tok.start])
+		case wildcardToken:
+			err = node.checkWildcard(tok.value, pattern[:tok.start])
+			node = node.wildcardEdge
+		default:
+			node = node.findStatic(tok.value)
+		}
+		// Patterns below a node that doesn't exist yet can't conflict
+		if err != nil || node == nil {
+			return nil, err
 		}
+	}
+	return node, nil
+}
 
-		// Split node
-		child := NewNode(search[:commonPrefix], false)
-
-		// Check for param name conflict
-		if node.isParam && child.isParam && !slicesEqual(child.paramNames, node.paramNames) {
-			slog.Error("Route param conflict",
-				"path", input,
-				"conflicting_param", node.value,
-				"existing_param", node.paramNames,
-				"new_route", input,
-			)
-			return nil
+// findParam returns the edge for param, or nil if there is none. Params at the same position
+// must have the same name unless their constraints differ.
+func (n *Node) findParam(param, prefix string) (*Node, error) {
+	name, constraint := splitParam(param)
+	for _, edge := range n.paramEdges {
+		edgeName, edgeConstraint := splitParam(edge.value)
+		if edgeConstraint != constraint {
+			continue
 		}
-		parent.updateEdge(search[0], child)
-		node.value = node.value[commonPrefix:]
-		node.isParam = strings.Contains(node.value, ":")
-		node.paramNames = getParamNames(node.value)
-		child.addEdge(node)
-		search = search[commonPrefix:]
-		newNode := NewNode(search, true)
-		if len(search) == 0 {
-			child.isComplete = true
-		} else {
-			child.addEdge(newNode)
+		if edgeName != name {
+			return nil, fmt.Errorf("%w: param %s conflicts with %s at %s", ErrConflict, param, edge.value, prefix)
 		}
+		return edge, nil
+	}
+	return nil, nil
+}
 
-		return newNode
+// checkWildcard returns an error if the node has a wildcard edge other than wildcard
+func (n *Node) checkWildcard(wildcard, prefix string) error {
+	if n.wildcardEdge != nil && n.wildcardEdge.value != wildcard {
+		return fmt.Errorf("%w: wildcard %s conflicts with %s at %s", ErrConflict, wildcard, n.wildcardEdge.value, prefix)
 	}
+	return nil
 }
 
-// slicesEqual checks if two slices of strings are equal
-func slicesEqual(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
+// findStatic returns the node for a static key below the node, or nil if inserting the key would
+// add or split edges
+func (n *Node) findStatic(key string) *Node {
+	node := n
+	for len(key) > 0 {
+		child := node.getEdge(key[0])
+		if child == nil || !strings.HasPrefix(key, child.value) {
+			return nil
+		}
+		node = child
+		key = key[len(child.value):]
 	}
-	return slices.Equal(a, b)
+	return node
 }
 
-// Search gets an item from the tree
-func (n *Node) Search(input string) (*Node, map[string]string) {
+// insertParam returns the edge for param, adding it if needed
+func (n *Node) insertParam(param, prefix string) (*Node, error) {
+	if edge, err := n.findParam(param, prefix); edge != nil || err != nil {
+		return edge, err
+	}
+
+	_, constraint := splitParam(param)
+	child := NewNode(param, false)
+	idx := len(n.paramEdges)
+	if constraint != "" {
+		matcher, err := newMatcher(constraint)
+		if err != nil {
+			return nil, err
+		}
+		child.matcher = matcher
+		// Keep the unconstrained param last so that it is tried after every constrained one
+		if idx > 0 && n.paramEdges[idx-1].matcher == nil {
+			idx--
+		}
+	}
+	n.paramEdges = slices.Insert(n.paramEdges, idx, child)
+	return child, nil
+}
+
+// insertStatic inserts a static key below the node, splitting edges as needed, and returns the node for the key
+func (n *Node) insertStatic(key string) *Node {
 	node := n
-	search := input
-	fullPath := ""
-	params := map[string]string{}
-	for {
-		// If search string is empty, we've found the deepest possible node
-		if len(search) == 0 {
-			if !node.isComplete {
-				return nil, nil
-			}
-			node.fullValue = fullPath
-			return node, params
+	for len(key) > 0 {
+		child := node.getEdge(key[0])
+
+		// No matching edge was found. Just create new edge.
+		if child == nil {
+			child = NewNode(key, false)
+			node.addEdge(child)
+			return child
 		}
 
-		// Get the child node for the first character of the search string
-		node = node.getEdge(search[0], true)
+		commonPrefix := getCommonPrefix(key, child.value)
 
-		// No match
-		if node == nil {
-			return nil, nil
+		// Split node
+		if commonPrefix < len(child.value) {
+			split := NewNode(key[:commonPrefix], false)
+			node.updateEdge(key[0], split)
+			child.value = child.value[commonPrefix:]
+			split.addEdge(child)
+			child = split
 		}
 
-		// Handle param search
-		if node.isParam {
-			searchSegments := strings.Split(search, "/")
-			nodeSegments := strings.Split(node.value, "/")
+		node = child
+		key = key[commonPrefix:]
+	}
+	return node
+}
 
-			if len(nodeSegments) > len(searchSegments) {
-				return nil, nil
-			}
+// Search gets an item from the tree. At every position static edges take precedence over
+// param edges, constrained before unconstrained, which take precedence over wildcard edges.
+// When a deeper part of the path doesn't match, the search backtracks and tries the next edge
+// in that order. A param followed by a literal in the same segment matches as much of the
+// segment as possible, e.g. :name.:ext matches archive.tar.gz with name archive.tar.
+func (n *Node) Search(input string) (*Node, map[string]string) {
+	return n.searchWith(input, false)
+}
 
-			paramIndex := 0
-			for i := range nodeSegments {
-				if i < len(searchSegments) {
-					if paramIndex > 0 && paramIndex > len(node.paramNames)-1 {
-						break
-					}
-					if nodeSegments[i] != searchSegments[i] && !strings.HasPrefix(nodeSegments[i], ":") {
-						return nil, nil
-					}
-					if strings.HasPrefix(nodeSegments[i], ":") {
-						if paramIndex < len(node.paramNames) {
+// SearchCaseInsensitive gets an item from the tree like Search, but matches static parts of the
+// pattern regardless of ASCII case. Param values keep their case.
+func (n *Node) SearchCaseInsensitive(input string) (*Node, map[string]string) {
+	return n.searchWith(input, true)
+}
+
+func (n *Node) searchWith(input string, fold bool) (*Node, map[string]string) {
+	var params Params
+	node := n.search(input, &params, fold)
+	if node == nil {
+		return nil, nil
+	}
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		m[p.Key] = p.Value
+	}
+	return node, m
+}
 
-							params[node.paramNames[paramIndex]] = searchSegments[i]
-							searchSegments[i] = nodeSegments[i]
-							paramIndex++
-						}
+// Lookup gets an item from the tree like Search, or like SearchCaseInsensitive with fold, and
+// appends the values of its params to params instead of allocating a map. Nothing is appended if
+// no item matches, so that a reused params slice doesn't cause allocations.
+func (n *Node) Lookup(path string, params *Params, fold bool) *Node {
+	return n.search(path, params, fold)
+}
+
+// search returns the complete node below n matching path. Params are only recorded
+// once a complete match is found, so abandoned branches leave no values behind.
+// With fold, static edges are matched regardless of ASCII case.
+func (n *Node) search(path string, params *Params, fold bool) *Node {
+	if len(path) == 0 {
+		if n.isComplete {
+			return n
+		}
+		// A wildcard also matches an empty remainder
+		if n.wildcardEdge != nil && n.wildcardEdge.isComplete {
+			*params = append(*params, Param{Key: n.wildcardEdge.paramName()})
+			return n.wildcardEdge
+		}
+		return nil
+	}
+
+	// Static match, also trying the edge for the other case of the label when folding
+	if node := n.searchStatic(path[0], path, params, fold); node != nil {
+		return node
+	}
+	if fold && swapCase(path[0]) != path[0] {
+		if node := n.searchStatic(swapCase(path[0]), path, params, fold); node != nil {
+			return node
+		}
+	}
+
+	// Param match within the segment, trying constrained params first
+	if len(n.paramEdges) > 0 {
+		segmentEnd := strings.IndexByte(path, '/')
+		if segmentEnd < 0 {
+			segmentEnd = len(path)
+		}
+		for _, edge := range n.paramEdges {
+			// A param followed by a literal in the same segment is tried first, ending as late as
+			// possible, before the param matching the whole segment
+			if edge.splitsSegment() {
+				for end := segmentEnd - 1; end > 0; end-- {
+					if node := edge.searchParam(path, end, params, fold); node != nil {
+						return node
 					}
 				}
 			}
-
-			search = strings.Join(searchSegments, "/")
-			fullPath += node.value
-
-			if len(search) > len(node.value) {
-				search = search[len(node.value):]
-			} else {
-				search = ""
+			if segmentEnd > 0 {
+				if node := edge.searchParam(path, segmentEnd, params, fold); node != nil {
+					return node
+				}
 			}
-			continue
 		}
+	}
 
-		// Find the common prefix between the search string and the node's value
-		commonPrefix := getCommonPrefix(search, node.value)
-		fullPath += search[:commonPrefix]
+	// Wildcard match of the rest of the path
+	if n.wildcardEdge != nil && n.wildcardEdge.isComplete {
+		*params = append(*params, Param{Key: n.wildcardEdge.paramName(), Value: path})
+		return n.wildcardEdge
+	}
 
-		// If the common prefix length equals the node's value length, continue searching
-		if commonPrefix == len(node.value) {
-			search = search[commonPrefix:]
-			continue
+	// No match
+	return nil
+}
+
+// searchStatic matches the static edge for label against the beginning of path and searches the rest of the path below it
+func (n *Node) searchStatic(label byte, path string, params *Params, fold bool) *Node {
+	child := n.getEdge(label)
+	if child == nil || !hasPrefix(path, child.value, fold) {
+		return nil
+	}
+	return child.search(path[len(child.value):], params, fold)
+}
+
+// searchParam matches the param node n against path[:end] and searches the rest of the path below it
+func (n *Node) searchParam(path string, end int, params *Params, fold bool) *Node {
+	if n.matcher != nil && !n.matcher(path[:end]) {
+		return nil
+	}
+	node := n.search(path[end:], params, fold)
+	if node != nil {
+		*params = append(*params, Param{Key: n.paramName(), Value: path[:end]})
+	}
+	return node
+}
+
+// hasPrefix reports whether path begins with prefix, regardless of ASCII case with fold
+func hasPrefix(path, prefix string, fold bool) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	if !fold {
+		return path[:len(prefix)] == prefix
+	}
+	for i := 0; i < len(prefix); i++ {
+		if path[i] != prefix[i] && swapCase(path[i]) != prefix[i] {
+			return false
 		}
+	}
+	return true
+}
 
-		// No match
-		return nil, nil
+// swapCase returns the other case of an ASCII letter, or c if it isn't a letter
+func swapCase(c byte) byte {
+	if isLetter(c) {
+		return c ^ 0x20
+	}
+	return c
+}
+
+// splitsSegment reports whether the node has a static edge continuing the current path segment
+func (n *Node) splitsSegment() bool {
+	for _, edge := range n.edges {
+		if edge.value[0] != '/' {
+			return true
+		}
 	}
+	return false
 }
 
 // getFirstMatchIdx performs a binary search to find the index of the first edge
@@ -235,29 +517,12 @@ func (n *Node) updateEdge(label byte, node *Node) {
 	panic("We're trying to replace a missing node. This should never happen.")
 }
 
-// getEdge returns the edge that matches the given label
-func (n *Node) getEdge(label byte, matchParam bool) *Node {
+// getEdge returns the static edge that matches the given label
+func (n *Node) getEdge(label byte) *Node {
 	idx := n.getFirstMatchIdx(label)
-
 	if idx < len(n.edges) && n.edges[idx].value[0] == label {
 		return n.edges[idx]
 	}
-
-	if !matchParam {
-		return nil
-	}
-
-	// There was no exact match, so check for slug edges
-	for _, e := range n.edges {
-		nodeSegments := strings.Split(e.value, "/")
-		if len(nodeSegments) == 0 {
-			continue
-		}
-		if strings.Contains(nodeSegments[0], ":") {
-			return e
-		}
-	}
-
 	return nil
 }
 
@@ -275,6 +540,15 @@ func getCommonPrefix(k1, k2 string) int {
 	return maxLen
 }
 
+// children returns the static, param and wildcard edges of the node
+func (n *Node) children() []*Node {
+	children := append(n.edges[:len(n.edges):len(n.edges)], n.paramEdges...)
+	if n.wildcardEdge != nil {
+		children = append(children[:len(children):len(children)], n.wildcardEdge)
+	}
+	return children
+}
+
 // GetAllCompleteItems returns all complete items in the tree
 func (n *Node) GetAllCompleteItems() []string {
 	var result []string
@@ -299,7 +573,7 @@ func dfsCompleteItems(node *Node, prefix string, result []string) []string {
 		result = append(result, prefix)
 	}
 
-	for _, edge := range node.edges {
+	for _, edge := range node.children() {
 		result = dfsCompleteItems(edge, prefix+edge.value, result)
 	}
 
@@ -312,7 +586,7 @@ func dfs(node *Node, result []string) []string {
 		result = append(result, node.value)
 	}
 
-	for _, child := range node.edges {
+	for _, child := range node.children() {
 		result = dfs(child, result)
 	}
 
@@ -1,10 +1,16 @@
 package radix
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -699,9 +705,11 @@ func TestInsertConflictParams(t *testing.T) {
 	tree := NewNode("", false)
 
 	tree.Insert("GET /users/:id")
-	n := tree.Insert("GET /users/:username")
+	n, err := tree.Insert("GET /users/:username")
 
 	assert.Nil(t, n)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.EqualError(t, err, "conflicting route: param :username conflicts with :id at GET /users/")
 }
 
 func TestSearchConflictStaticAndParam(t *testing.T) {
@@ -718,3 +726,816 @@ func TestSearchConflictStaticAndParam(t *testing.T) {
 	assert.NotNil(t, result)
 	assert.Equal(t, "GET /users/:id", result.fullValue)
 }
+
+func TestInsertWithHandler(t *testing.T) {
+	tree := NewNode("", false)
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, nil
+	}
+
+	assert.NoError(t, tree.InsertWithHandler("GET", "/users/:id", handler))
+	assert.NoError(t, tree.InsertWithHandler("DELETE", "/users/:id", handler))
+	assert.NoError(t, tree.InsertWithHandler("POST", "/users", handler))
+
+	result, params := tree.Search("/users/123")
+	assert.NotNil(t, result)
+	assert.Equal(t, map[string]string{"id": "123"}, params)
+	assert.Equal(t, []string{"DELETE", "GET"}, result.Methods())
+	assert.NotNil(t, result.Handler("GET"))
+	assert.Nil(t, result.Handler("POST"))
+
+	result, _ = tree.Search("/users")
+	assert.NotNil(t, result)
+	assert.Equal(t, []string{"POST"}, result.Methods())
+}
+
+func TestInsertPrefixOfExistingNode(t *testing.T) {
+	tree := NewNode("", false)
+
+	tree.Insert("/users/:id")
+	n, err := tree.Insert("/users")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, n)
+	result, _ := tree.Search("/users")
+	assert.Same(t, n, result)
+	assert.Equal(t, []string{"/users", "/users/:id"}, tree.GetAllCompleteItems())
+}
+
+func TestSearchWildcard(t *testing.T) {
+	input := []string{
+		"/",
+		"/static/*filepath",
+		"/static/css/main.css",
+		"/static/:version/manifest.json",
+		"/api/{proxy+}",
+		"/api/users",
+		"/*spa",
+	}
+	testCases := []SearchTestCase{
+		{id: 1, input: input, search: "/", output: "/", params: map[string]string{}},
+		{
+			id:     2,
+			input:  input,
+			search: "/static/js/app/main.js",
+			output: "/static/*filepath",
+			params: map[string]string{"filepath": "js/app/main.js"},
+		},
+		{
+			id:     3,
+			input:  input,
+			search: "/static/",
+			output: "/static/*filepath",
+			params: map[string]string{"filepath": ""},
+		},
+		{id: 4, input: input, search: "/static/css/main.css", output: "/static/css/main.css", params: map[string]string{}},
+		{
+			id:     5,
+			input:  input,
+			search: "/static/v2/manifest.json",
+			output: "/static/:version/manifest.json",
+			params: map[string]string{"version": "v2"},
+		},
+		{
+			id:     6,
+			input:  input,
+			search: "/api/orders/123/items",
+			output: "/api/*proxy",
+			params: map[string]string{"proxy": "orders/123/items"},
+		},
+		{id: 7, input: input, search: "/api/users", output: "/api/users", params: map[string]string{}},
+		{
+			id:     8,
+			input:  input,
+			search: "/dashboard/settings",
+			output: "/*spa",
+			params: map[string]string{"spa": "dashboard/settings"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			tree.Insert(j)
+		}
+		result, params := tree.Search(tc.search)
+		if tc.notFoundExpected {
+			assert.Nil(t, result, fmt.Sprintf("Test id %d failed: expected nil result, but got %v", tc.id, result))
+		} else {
+			assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+			assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+			assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+		}
+	}
+}
+
+func TestInsertConflictWildcards(t *testing.T) {
+	tree := NewNode("", false)
+
+	tree.Insert("/static/*filepath")
+	n, err := tree.Insert("/static/{proxy+}")
+
+	assert.Nil(t, n)
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestSearchBraceParams(t *testing.T) {
+	input := []string{
+		"/users/{id}",
+		"/users/{id}/orders/:orderId",
+		"/users/login",
+		"/files/{proxy+}",
+	}
+	testCases := []SearchTestCase{
+		{id: 1, input: input, search: "/users/42", output: "/users/:id", params: map[string]string{"id": "42"}},
+		{
+			id:     2,
+			input:  input,
+			search: "/users/42/orders/7",
+			output: "/users/:id/orders/:orderId",
+			params: map[string]string{"id": "42", "orderId": "7"},
+		},
+		{id: 3, input: input, search: "/users/login", output: "/users/login", params: map[string]string{}},
+		{
+			id:     4,
+			input:  input,
+			search: "/files/docs/readme.md",
+			output: "/files/*proxy",
+			params: map[string]string{"proxy": "docs/readme.md"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			tree.Insert(j)
+		}
+		result, params := tree.Search(tc.search)
+		assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+		assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+		assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+	}
+}
+
+func TestInsertConflictBraceAndColonParams(t *testing.T) {
+	tree := NewNode("", false)
+
+	tree.Insert("/users/:id")
+	_, err := tree.Insert("/users/{id}/orders")
+	assert.NoError(t, err)
+	_, err = tree.Insert("/users/{userId}")
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestInsertDuplicateRoute(t *testing.T) {
+	tree := NewNode("", false)
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	duplicate := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 500}, nil
+	}
+
+	assert.NoError(t, tree.InsertWithHandler("GET", "/users/:id", handler))
+	assert.NoError(t, tree.InsertWithHandler("POST", "/users/:id", handler))
+	err := tree.InsertWithHandler("GET", "/users/{id}", duplicate)
+
+	assert.ErrorIs(t, err, ErrDuplicateRoute)
+	assert.EqualError(t, err, "duplicate route: /users/:id is already registered")
+	result, _ := tree.Search("/users/1")
+	resp, _ := result.Handler("GET")(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestInsertInvalidPattern(t *testing.T) {
+	testCases := []struct {
+		id      int
+		pattern string
+		err     string
+	}{
+		{1, "/users/:", "invalid route pattern: unnamed param in segment 2"},
+		{2, "/users/:/orders", "invalid route pattern: unnamed param in segment 2"},
+		{3, "/static/*", "invalid route pattern: unnamed param in segment 2"},
+		{4, "/users/{}", "invalid route pattern: unnamed param in segment 2"},
+		{5, "/users/{id", "invalid route pattern: malformed segment {id"},
+		{6, "/users/id}", "invalid route pattern: malformed segment id}"},
+		{7, "/users/:id/orders/{id}", "invalid route pattern: duplicate param name id"},
+		{8, "/static/*filepath/edit", "invalid route pattern: wildcard *filepath must be the last segment"},
+		{9, "/api/{proxy+}/edit", "invalid route pattern: wildcard *proxy must be the last segment"},
+		{10, "/orders/:id<int", "invalid route pattern: malformed segment :id<int"},
+		{11, "/orders/:id<>", "invalid route pattern: empty constraint in segment :id<>"},
+		{12, "/orders/:<int>", "invalid route pattern: unnamed param in segment 2"},
+		{13, "/orders/:id<integer>", "invalid route pattern: unknown constraint integer"},
+		{14, "/files/*path<[a-z]+>", "invalid route pattern: wildcard *path<[a-z]+> can't have a constraint"},
+		{
+			15,
+			"/files/:name<[a-z>",
+			"invalid route pattern: constraint [a-z: error parsing regexp: missing closing ]: `[a-z)$`",
+		},
+		{16, "/files/:name:ext", "invalid route pattern: params in segment :name:ext must be separated by a literal"},
+		{17, "/files/:name<[a-z]+>:ext", "invalid route pattern: params in segment :name<[a-z]+>:ext must be separated by a literal"},
+		{18, "/files/{name}.{name}", "invalid route pattern: duplicate param name name"},
+		{19, "/files/id{id}", "invalid route pattern: malformed segment id{id}"},
+		{20, "/items/:id?/edit", "invalid route pattern: optional param :id? must be a trailing segment"},
+		{21, "/files/:name.:ext?", "invalid route pattern: optional param :name.:ext? must be a trailing segment"},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		n, err := tree.Insert(tc.pattern)
+
+		assert.Nil(t, n, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.ErrorIs(t, err, ErrInvalidPattern, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.EqualError(t, err, tc.err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Empty(t, tree.GetAllCompleteItems(), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestSearchBacktracking(t *testing.T) {
+	input := []string{
+		"/pet/findByStatus",
+		"/pet/findByTags",
+		"/pet/:petId",
+		"/pet/:petId/uploadImage",
+		"/pet/:petId/*rest",
+		"/users/:id/profile",
+		"/users/admin/settings",
+		"/files/*filepath",
+		"/files/:name/meta",
+	}
+	testCases := []SearchTestCase{
+		{id: 1, input: input, search: "/pet/findByStatus", output: "/pet/findByStatus", params: map[string]string{}},
+		{id: 2, input: input, search: "/pet/fox", output: "/pet/:petId", params: map[string]string{"petId": "fox"}},
+		{id: 3, input: input, search: "/pet/findBy", output: "/pet/:petId", params: map[string]string{"petId": "findBy"}},
+		{id: 4, input: input, search: "/pet/findByName", output: "/pet/:petId", params: map[string]string{"petId": "findByName"}},
+		{
+			id:     5,
+			input:  input,
+			search: "/pet/findByStatus/uploadImage",
+			output: "/pet/:petId/uploadImage",
+			params: map[string]string{"petId": "findByStatus"},
+		},
+		{
+			id:     6,
+			input:  input,
+			search: "/pet/123/photos/1",
+			output: "/pet/:petId/*rest",
+			params: map[string]string{"petId": "123", "rest": "photos/1"},
+		},
+		{
+			id:     7,
+			input:  input,
+			search: "/users/admin/profile",
+			output: "/users/:id/profile",
+			params: map[string]string{"id": "admin"},
+		},
+		{id: 8, input: input, search: "/users/admin/settings", output: "/users/admin/settings", params: map[string]string{}},
+		{
+			id:     9,
+			input:  input,
+			search: "/files/readme/meta",
+			output: "/files/:name/meta",
+			params: map[string]string{"name": "readme"},
+		},
+		{
+			id:     10,
+			input:  input,
+			search: "/files/readme/raw",
+			output: "/files/*filepath",
+			params: map[string]string{"filepath": "readme/raw"},
+		},
+		{id: 11, input: input, search: "/users/admin", notFoundExpected: true},
+		{id: 12, input: input, search: "/pet/", notFoundExpected: true},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			tree.Insert(j)
+		}
+		result, params := tree.Search(tc.search)
+		if tc.notFoundExpected {
+			assert.Nil(t, result, fmt.Sprintf("Test id %d failed: expected nil result, but got %v", tc.id, result))
+		} else {
+			assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+			assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+			assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+		}
+	}
+}
+
+// referenceMatch matches path against every pattern segment by segment and returns the
+// highest priority match, comparing segment kinds from left to right: static, then param,
+// then wildcard.
+func referenceMatch(patterns []string, path string) (string, map[string]string) {
+	best := ""
+	var bestParams map[string]string
+	var bestRank []int
+	pathSegments := strings.Split(path, "/")
+
+	for _, pattern := range patterns {
+		params, rank, ok := referenceMatchPattern(pattern, pathSegments)
+		if !ok {
+			continue
+		}
+		if bestRank == nil || slices.Compare(rank, bestRank) < 0 {
+			best, bestParams, bestRank = pattern, params, rank
+		}
+	}
+	return best, bestParams
+}
+
+// referenceMatchPattern matches a single pattern and returns the params and the kind of each segment
+func referenceMatchPattern(pattern string, pathSegments []string) (map[string]string, []int, bool) {
+	params := map[string]string{}
+	var rank []int
+	patternSegments := strings.Split(pattern, "/")
+	for i, segment := range patternSegments {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			if i >= len(pathSegments) {
+				return nil, nil, false
+			}
+			params[segment[1:]] = strings.Join(pathSegments[i:], "/")
+			return params, append(rank, 2), true
+		case i >= len(pathSegments):
+			return nil, nil, false
+		case strings.HasPrefix(segment, ":"):
+			if pathSegments[i] == "" {
+				return nil, nil, false
+			}
+			params[segment[1:]] = pathSegments[i]
+			rank = append(rank, 1)
+		default:
+			if pathSegments[i] != segment {
+				return nil, nil, false
+			}
+			rank = append(rank, 0)
+		}
+	}
+	if len(patternSegments) != len(pathSegments) {
+		return nil, nil, false
+	}
+	return params, rank, true
+}
+
+// randomPattern returns a random pattern. Params are named after their position so that
+// patterns never conflict.
+func randomPattern(rng *rand.Rand) string {
+	statics := []string{"a", "b", "ab", "abc", "ba"}
+	segments := []string{""}
+	n := 1 + rng.Intn(4)
+	for i := 1; i <= n; i++ {
+		switch r := rng.Intn(10); {
+		case r < 6:
+			segments = append(segments, statics[rng.Intn(len(statics))])
+		case r < 9 || i < n:
+			segments = append(segments, fmt.Sprintf(":p%d", i))
+		default:
+			segments = append(segments, "*w")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// randomPath returns a random path built from the same static segments as randomPattern
+func randomPath(rng *rand.Rand) string {
+	values := []string{"a", "b", "ab", "abc", "ba", "x", "abx", ""}
+	segments := []string{""}
+	n := 1 + rng.Intn(5)
+	for i := 0; i < n; i++ {
+		segments = append(segments, values[rng.Intn(len(values))])
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestSearchMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for iteration := 0; iteration < 500; iteration++ {
+		tree := NewNode("", false)
+		var patterns []string
+		for i := 0; i < 1+rng.Intn(15); i++ {
+			pattern := randomPattern(rng)
+			if !slices.Contains(patterns, pattern) {
+				patterns = append(patterns, pattern)
+			}
+			tree.Insert(pattern)
+		}
+
+		for i := 0; i < 50; i++ {
+			path := randomPath(rng)
+			expected, expectedParams := referenceMatch(patterns, path)
+			result, params := tree.Search(path)
+			if expected == "" {
+				assert.Nil(t, result, "patterns %v, path %q: expected no match", patterns, path)
+				continue
+			}
+			if assert.NotNil(t, result, "patterns %v, path %q: expected %s", patterns, path, expected) {
+				assert.Equal(t, expected, result.fullValue, "patterns %v, path %q", patterns, path)
+				assert.Equal(t, expectedParams, params, "patterns %v, path %q", patterns, path)
+			}
+		}
+	}
+}
+
+func TestSearchConstraints(t *testing.T) {
+	input := []string{
+		"/orders/:id<int>",
+		"/orders/:slug",
+		"/orders/:code<[A-Z]{3}>/items",
+		"/orders/{ref<uuid>}/items",
+		"/orders/:slug/items",
+		"/files/:name<[a-z0-9-]+>",
+		"/users/:id<int>/orders/:orderId<int>",
+	}
+	testCases := []SearchTestCase{
+		{id: 1, input: input, search: "/orders/42", output: "/orders/:id<int>", params: map[string]string{"id": "42"}},
+		{id: 2, input: input, search: "/orders/latest", output: "/orders/:slug", params: map[string]string{"slug": "latest"}},
+		{
+			id:     3,
+			input:  input,
+			search: "/orders/ABC/items",
+			output: "/orders/:code<[A-Z]{3}>/items",
+			params: map[string]string{"code": "ABC"},
+		},
+		{
+			id:     4,
+			input:  input,
+			search: "/orders/3f2c1d6e-8a4b-4c2e-9f1a-0b7d5e6c4a21/items",
+			output: "/orders/:ref<uuid>/items",
+			params: map[string]string{"ref": "3f2c1d6e-8a4b-4c2e-9f1a-0b7d5e6c4a21"},
+		},
+		{
+			id:     5,
+			input:  input,
+			search: "/orders/ABCD/items",
+			output: "/orders/:slug/items",
+			params: map[string]string{"slug": "ABCD"},
+		},
+		{id: 6, input: input, search: "/files/report-2024", output: "/files/:name<[a-z0-9-]+>", params: map[string]string{"name": "report-2024"}},
+		{id: 7, input: input, search: "/files/Report.pdf", notFoundExpected: true},
+		{
+			id:     8,
+			input:  input,
+			search: "/users/1/orders/2",
+			output: "/users/:id<int>/orders/:orderId<int>",
+			params: map[string]string{"id": "1", "orderId": "2"},
+		},
+		{id: 9, input: input, search: "/users/1/orders/x", notFoundExpected: true},
+		{id: 10, input: input, search: "/users/x/orders/2", notFoundExpected: true},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			_, err := tree.Insert(j)
+			assert.NoError(t, err, fmt.Sprintf("Test id %d failed: unexpected error inserting %s", tc.id, j))
+		}
+		result, params := tree.Search(tc.search)
+		if tc.notFoundExpected {
+			assert.Nil(t, result, fmt.Sprintf("Test id %d failed: expected nil result, but got %v", tc.id, result))
+		} else {
+			assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+			assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+			assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+		}
+	}
+}
+
+func TestInsertConflictConstrainedParams(t *testing.T) {
+	tree := NewNode("", false)
+
+	_, err := tree.Insert("/orders/:id<int>")
+	assert.NoError(t, err)
+	_, err = tree.Insert("/orders/:slug")
+	assert.NoError(t, err)
+	_, err = tree.Insert("/orders/:orderId<int>/items")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.EqualError(t, err, "conflicting route: param :orderId<int> conflicts with :id<int> at /orders/")
+	_, err = tree.Insert("/orders/:name")
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestSearchMultipleParamsInSegment(t *testing.T) {
+	input := []string{
+		"/files/:name.:ext",
+		"/files/:name.:ext/meta",
+		"/files/:name",
+		"/reports/:from-:to",
+		"/ranges/:from<[0-9]{4}-[0-9]{2}-[0-9]{2}>-:to",
+		"/exports/report-:date.csv",
+		"/v1/things:batchGet",
+		"/v1/things/:id",
+	}
+	testCases := []SearchTestCase{
+		{
+			id:     1,
+			input:  input,
+			search: "/files/report.pdf",
+			output: "/files/:name.:ext",
+			params: map[string]string{"name": "report", "ext": "pdf"},
+		},
+		{
+			id:     2,
+			input:  input,
+			search: "/files/archive.tar.gz",
+			output: "/files/:name.:ext",
+			params: map[string]string{"name": "archive.tar", "ext": "gz"},
+		},
+		{
+			id:     3,
+			input:  input,
+			search: "/files/report.pdf/meta",
+			output: "/files/:name.:ext/meta",
+			params: map[string]string{"name": "report", "ext": "pdf"},
+		},
+		{id: 4, input: input, search: "/files/README", output: "/files/:name", params: map[string]string{"name": "README"}},
+		{id: 5, input: input, search: "/files/README.", output: "/files/:name", params: map[string]string{"name": "README."}},
+		{
+			id:     6,
+			input:  input,
+			search: "/reports/2024-2025",
+			output: "/reports/:from-:to",
+			params: map[string]string{"from": "2024", "to": "2025"},
+		},
+		{
+			id:     7,
+			input:  input,
+			search: "/ranges/2024-01-01-2024-12-31",
+			output: "/ranges/:from<[0-9]{4}-[0-9]{2}-[0-9]{2}>-:to",
+			params: map[string]string{"from": "2024-01-01", "to": "2024-12-31"},
+		},
+		{
+			id:     8,
+			input:  input,
+			search: "/exports/report-2024-01-01.csv",
+			output: "/exports/report-:date.csv",
+			params: map[string]string{"date": "2024-01-01"},
+		},
+		{id: 9, input: input, search: "/exports/report-.csv", notFoundExpected: true},
+		{id: 10, input: input, search: "/v1/things:batchGet", output: "/v1/things:batchGet", params: map[string]string{}},
+		{id: 11, input: input, search: "/reports/2024", notFoundExpected: true},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			_, err := tree.Insert(j)
+			assert.NoError(t, err, fmt.Sprintf("Test id %d failed: unexpected error inserting %s", tc.id, j))
+		}
+		result, params := tree.Search(tc.search)
+		if tc.notFoundExpected {
+			assert.Nil(t, result, fmt.Sprintf("Test id %d failed: expected nil result, but got %v", tc.id, result))
+		} else {
+			assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+			assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+			assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+		}
+	}
+}
+
+func TestInsertWithHandlerOptionalParams(t *testing.T) {
+	tree := NewNode("", false)
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, nil
+	}
+
+	assert.NoError(t, tree.InsertWithHandler("GET", "/items/:id?", handler))
+	assert.NoError(t, tree.InsertWithHandler("GET", "/archive/{year<int>?}/{month<int>?}", handler))
+	assert.Equal(
+		t,
+		[]string{"/archive", "/archive/:year<int>", "/archive/:year<int>/:month<int>", "/items", "/items/:id"},
+		tree.GetAllCompleteItems(),
+	)
+
+	testCases := []struct {
+		id     int
+		search string
+		output string
+		params map[string]string
+	}{
+		{1, "/items", "/items", map[string]string{}},
+		{2, "/items/42", "/items/:id", map[string]string{"id": "42"}},
+		{3, "/archive", "/archive", map[string]string{}},
+		{4, "/archive/2024", "/archive/:year<int>", map[string]string{"year": "2024"}},
+		{5, "/archive/2024/10", "/archive/:year<int>/:month<int>", map[string]string{"year": "2024", "month": "10"}},
+	}
+	for _, tc := range testCases {
+		result, params := tree.Search(tc.search)
+		assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+		assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed", tc.id))
+		assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed", tc.id))
+		assert.NotNil(t, result.Handler("GET"), fmt.Sprintf("Test id %d failed", tc.id))
+	}
+
+	// A duplicate of any of the expanded patterns registers none of them
+	err := tree.InsertWithHandler("POST", "/orders/:id", handler)
+	assert.NoError(t, err)
+	err = tree.InsertWithHandler("POST", "/orders/:id?", handler)
+	assert.ErrorIs(t, err, ErrDuplicateRoute)
+	result, _ := tree.Search("/orders")
+	assert.Nil(t, result)
+
+	// A conflict in any of the expanded patterns leaves the tree unchanged
+	assert.NoError(t, tree.InsertWithHandler("GET", "/a/:y", handler))
+	items := tree.GetAllNodeValues()
+	err = tree.InsertWithHandler("GET", "/a/:x?", handler)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, items, tree.GetAllNodeValues())
+	result, _ = tree.Search("/a")
+	assert.Nil(t, result)
+}
+
+func TestCheck(t *testing.T) {
+	tree := NewNode("", false)
+	for _, pattern := range []string{"/users/:id", "/users/:id<int>/orders", "/static/*filepath", "/files"} {
+		_, err := tree.Insert(pattern)
+		assert.NoError(t, err)
+	}
+	items := tree.GetAllNodeValues()
+
+	testCases := []struct {
+		id       int
+		pattern  string
+		expected error
+	}{
+		{1, "/users/:id", nil},
+		{2, "/users/{id}/profile", nil},
+		{3, "/users/:userId", ErrConflict},
+		{4, "/users/:userId<int>", ErrConflict},
+		{5, "/users/:userId<uuid>", nil},
+		{6, "/static/*path", ErrConflict},
+		{7, "/fil", nil},
+		{8, "/files/:name/:name", ErrInvalidPattern},
+		{9, "/other/:id", nil},
+	}
+
+	for _, tc := range testCases {
+		err := tree.Check(tc.pattern)
+		if tc.expected == nil {
+			assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		} else {
+			assert.ErrorIs(t, err, tc.expected, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		}
+	}
+	assert.Equal(t, items, tree.GetAllNodeValues())
+}
+
+func TestSearchCaseInsensitive(t *testing.T) {
+	input := []string{
+		"/users/:id",
+		"/users/:id/Profile",
+		"/Users/admin",
+		"/files/:name.PDF",
+		"/static/*filepath",
+		"/orders/:id<alpha>",
+		"/v1/things:batchGet",
+	}
+	testCases := []SearchTestCase{
+		{id: 1, input: input, search: "/USERS/AbC", output: "/users/:id", params: map[string]string{"id": "AbC"}},
+		{id: 2, input: input, search: "/users/AbC/profile", output: "/users/:id/Profile", params: map[string]string{"id": "AbC"}},
+		{id: 3, input: input, search: "/USERS/ADMIN", output: "/Users/admin", params: map[string]string{}},
+		// Edges in the case of the path are tried first
+		{id: 10, input: input, search: "/users/ADMIN", output: "/users/:id", params: map[string]string{"id": "ADMIN"}},
+		{id: 4, input: input, search: "/Files/Report.pdf", output: "/files/:name.PDF", params: map[string]string{"name": "Report"}},
+		{id: 5, input: input, search: "/STATIC/CSS/App.css", output: "/static/*filepath", params: map[string]string{"filepath": "CSS/App.css"}},
+		{id: 6, input: input, search: "/ORDERS/Abc", output: "/orders/:id<alpha>", params: map[string]string{"id": "Abc"}},
+		{id: 7, input: input, search: "/V1/THINGS:BATCHGET", output: "/v1/things:batchGet", params: map[string]string{}},
+		{id: 8, input: input, search: "/orders/123", notFoundExpected: true},
+		{id: 9, input: input, search: "/users-x", notFoundExpected: true},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		for _, j := range tc.input {
+			tree.Insert(j)
+		}
+		result, params := tree.SearchCaseInsensitive(tc.search)
+		if tc.notFoundExpected {
+			assert.Nil(t, result, fmt.Sprintf("Test id %d failed: expected nil result, but got %v", tc.id, result))
+		} else {
+			assert.NotNil(t, result, fmt.Sprintf("Test id %d failed: expected non-nil result, but got nil", tc.id))
+			assert.Equal(t, tc.output, result.fullValue, fmt.Sprintf("Test id %d failed: expected output %s, but got %s", tc.id, tc.output, result.fullValue))
+			assert.Equal(t, tc.params, params, fmt.Sprintf("Test id %d failed: expected params %v, but got %v", tc.id, tc.params, params))
+		}
+	}
+
+	// Search stays case sensitive
+	tree := NewNode("", false)
+	tree.Insert("/users/:id")
+	result, _ := tree.Search("/USERS/1")
+	assert.Nil(t, result)
+}
+
+func TestNodePath(t *testing.T) {
+	testCases := []struct {
+		id       int
+		pattern  string
+		params   map[string]string
+		expected string
+	}{
+		{1, "/users", map[string]string{}, "/users"},
+		{2, "/users/:id/orders/{orderId}", map[string]string{"id": "AbC", "orderId": "7"}, "/users/AbC/orders/7"},
+		{3, "/files/:name.:ext", map[string]string{"name": "report", "ext": "pdf"}, "/files/report.pdf"},
+		{4, "/orders/:id<int>", map[string]string{"id": "42"}, "/orders/42"},
+		{5, "/static/*filepath", map[string]string{"filepath": "css/app.css"}, "/static/css/app.css"},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		node, err := tree.Insert(tc.pattern)
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Equal(t, tc.expected, node.Path(tc.params), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestNodeParams(t *testing.T) {
+	testCases := []struct {
+		id       int
+		pattern  string
+		expected []string
+	}{
+		{1, "/users", nil},
+		{2, "/users/:id/orders/{orderId}", []string{"id", "orderId"}},
+		{3, "/files/:name.:ext", []string{"name", "ext"}},
+		{4, "/orders/:id<int>", []string{"id"}},
+		{5, "/static/{dir}/*filepath", []string{"dir", "filepath"}},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		node, err := tree.Insert(tc.pattern)
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Equal(t, tc.expected, node.Params(), fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestNodeURL(t *testing.T) {
+	testCases := []struct {
+		id            int
+		pattern       string
+		params        map[string]string
+		expected      string
+		expectedError string
+	}{
+		{1, "/users", map[string]string{}, "/users", ""},
+		{2, "/users/:id/orders/{orderId}", map[string]string{"id": "AbC", "orderId": "7"}, "/users/AbC/orders/7", ""},
+		{3, "/files/:name.:ext", map[string]string{"name": "my report", "ext": "pdf"}, "/files/my%20report.pdf", ""},
+		{4, "/files/:name", map[string]string{"name": "a/b"}, "/files/a%2Fb", ""},
+		{5, "/static/*filepath", map[string]string{"filepath": "css/app v2.css"}, "/static/css/app%20v2.css", ""},
+		{6, "/orders/:id<int>", map[string]string{"id": "42"}, "/orders/42", ""},
+		{7, "/orders/:id<int>", map[string]string{"id": "abc"}, "", `param id: value "abc" doesn't satisfy constraint int`},
+		{8, "/users/:id", map[string]string{"id": ""}, "", "param id is empty"},
+	}
+
+	for _, tc := range testCases {
+		tree := NewNode("", false)
+		node, err := tree.Insert(tc.pattern)
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		url, err := node.URL(tc.params)
+		if tc.expectedError != "" {
+			assert.EqualError(t, err, tc.expectedError, fmt.Sprintf("Failed test id: %d\n", tc.id))
+			continue
+		}
+		assert.NoError(t, err, fmt.Sprintf("Failed test id: %d\n", tc.id))
+		assert.Equal(t, tc.expected, url, fmt.Sprintf("Failed test id: %d\n", tc.id))
+	}
+}
+
+func TestSearchConcurrent(t *testing.T) {
+	tree := NewNode("", false)
+	patterns := []string{"/users", "/users/:id<int>", "/users/:id", "/files/:name.:ext", "/static/*filepath"}
+	for _, pattern := range patterns {
+		_, err := tree.Insert(pattern)
+		assert.NoError(t, err)
+	}
+	testCases := []struct {
+		path     string
+		expected string
+		params   map[string]string
+	}{
+		{"/users", "/users", map[string]string{}},
+		{"/users/42", "/users/:id<int>", map[string]string{"id": "42"}},
+		{"/users/alice", "/users/:id", map[string]string{"id": "alice"}},
+		{"/files/archive.tar.gz", "/files/:name.:ext", map[string]string{"name": "archive.tar", "ext": "gz"}},
+		{"/static/css/app.css", "/static/*filepath", map[string]string{"filepath": "css/app.css"}},
+	}
+
+	// Search doesn't modify the tree, so it is safe for concurrent use. Run with -race to check.
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tc := testCases[(g+i)%len(testCases)]
+				node, params := tree.Search(tc.path)
+				if assert.NotNil(t, node, tc.path) {
+					assert.Equal(t, tc.expected, node.fullValue, tc.path)
+				}
+				assert.Equal(t, tc.params, params, tc.path)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -3,7 +3,16 @@ package lambdamux
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/D-Andreev/lambdamux/internal/radix"
 	"github.com/aws/aws-lambda-go/events"
@@ -12,56 +21,431 @@ import (
 // HandlerFunc defines the function signature for request handlers
 type HandlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 
+// ErrorHandlerFunc defines the function signature for converting a handler error to a response
+type ErrorHandlerFunc func(context.Context, events.APIGatewayProxyRequest, error) events.APIGatewayProxyResponse
+
+var (
+	// ErrInvalidPattern is reported when a route pattern is malformed, e.g. an unnamed param or a
+	// wildcard that isn't the last segment
+	ErrInvalidPattern = radix.ErrInvalidPattern
+	// ErrRouteConflict is reported when a route uses a different param or wildcard name than an
+	// already registered route at the same position
+	ErrRouteConflict = radix.ErrConflict
+	// ErrDuplicateRoute is reported when a route is registered twice for the same method and pattern
+	ErrDuplicateRoute = radix.ErrDuplicateRoute
+)
+
 // LambdaMux is a request multiplexer for AWS Lambda functions
 type LambdaMux struct {
-	tree *radix.Node
+	tree             *radix.Node
+	routes           []*Route
+	groups           map[string]*routeGroup // keyed by method and normalized pattern
+	handler          HandlerFunc
+	middlewares      []MiddlewareFunc
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	errorHandler     ErrorHandlerFunc
+	trailingSlash    TrailingSlashPolicy
+	cleanPath        bool
+	casePolicy       CasePolicy
+	useRawPath       bool
+	basePath         string
+	stripStage       bool
+	compiled         atomic.Bool
+	validated        atomic.Bool // set once the routes were validated, by Validate or the first Handle call
 }
 
 // NewLambdaMux creates and returns a new LambdaMux instance
 func NewLambdaMux() *LambdaMux {
-	return &LambdaMux{
-		tree: radix.NewNode("", false),
+	r := &LambdaMux{
+		tree:             radix.NewNode("", false),
+		groups:           map[string]*routeGroup{},
+		notFound:         defaultNotFound,
+		methodNotAllowed: defaultMethodNotAllowed,
+	}
+	r.handler = r.dispatch
+	return r
+}
+
+// NotFound sets the handler called when no route matches the request path
+func (r *LambdaMux) NotFound(handler HandlerFunc) {
+	r.checkMutable()
+	r.notFound = handler
+}
+
+// MethodNotAllowed sets the handler called when the request path matches a route registered only
+// for other methods. The Allow header is added to its response unless the handler sets it.
+func (r *LambdaMux) MethodNotAllowed(handler HandlerFunc) {
+	r.checkMutable()
+	r.methodNotAllowed = handler
+}
+
+// ErrorHandler sets the function called when a handler returns a non-nil error. Its response is
+// returned to the Lambda runtime instead of the error. Without an error handler, errors are returned
+// as is, which API Gateway reports as a 502. For errors returned by a route handler or its route
+// middleware, the error handler gets the request as it was routed, with PathParameters set and
+// req.Path without the stage or base path, and router middleware sees its response. Errors returned
+// by router middleware or the NotFound and MethodNotAllowed handlers get the request as received.
+func (r *LambdaMux) ErrorHandler(handler ErrorHandlerFunc) {
+	r.checkMutable()
+	r.errorHandler = handler
+}
+
+// anyMethod is the method key used for routes registered with ANY
+const anyMethod = "*"
+
+// methodName returns the name of a route method as it is registered, ANY for anyMethod
+func methodName(method string) string {
+	if method == anyMethod {
+		return "ANY"
+	}
+	return method
+}
+
+// addRoute inserts a route in the tree. Routes with the same method and pattern share a route
+// group in the tree, which picks a route by its matchers. Registration errors are kept on the
+// route and reported by Validate.
+func (r *LambdaMux) addRoute(method, path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	rt := r.newRoute(method, path, handler, middlewares)
+	if !strings.HasPrefix(path, "/") {
+		rt.err = fmt.Errorf("%w: path must begin with /", ErrInvalidPattern)
+		return rt
+	}
+
+	// Every expanded pattern is checked before any is registered, so that an invalid route
+	// leaves no handler behind
+	patterns := radix.Expand(path)
+	for _, pattern := range patterns {
+		if err := r.tree.Check(pattern); err != nil {
+			rt.err = err
+			return rt
+		}
+	}
+	for _, pattern := range patterns {
+		key := method + " " + pattern
+		group, ok := r.groups[key]
+		if !ok {
+			group = &routeGroup{}
+			if err := r.tree.InsertWithHandler(method, pattern, group.handle); err != nil {
+				rt.err = err
+				return rt
+			}
+			// The pattern is already in the tree, so Insert returns its node
+			group.node, _ = r.tree.Insert(pattern)
+			r.groups[key] = group
+		}
+		group.routes = append(group.routes, rt)
+		rt.patterns = append(rt.patterns, pattern)
+		rt.nodes = append(rt.nodes, group.node)
+	}
+	return rt
+}
+
+// newRoute records a route on the router without inserting it in the tree
+func (r *LambdaMux) newRoute(method, path string, handler HandlerFunc, middlewares []MiddlewareFunc) *Route {
+	r.checkMutable()
+	rt := &Route{
+		mux:         r,
+		method:      method,
+		pattern:     path,
+		handler:     handler,
+		middlewares: middlewares,
+		chained:     chain(handler, middlewares),
+	}
+	r.routes = append(r.routes, rt)
+	return rt
+}
+
+// Validate returns an error listing every route that could not be registered, or nil if all
+// routes were registered. Invalid routes are not served, so call Validate or MustCompile once
+// all routes are registered to fail fast at cold start. A route with the same method, pattern
+// and matchers as an earlier route is reported as a duplicate.
+func (r *LambdaMux) Validate() error {
+	r.validated.Store(true)
+	var errs []error
+	for _, rt := range r.routes {
+		err := rt.err
+		if err == nil {
+			err = r.duplicateError(rt)
+		}
+		if err == nil {
+			err = r.duplicateNameError(rt)
+		}
+		if err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s %s: %w", methodName(rt.method), rt.pattern, err))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("lambdamux: %d invalid route(s):\n%w", len(errs), errors.Join(errs...))
+}
+
+// duplicateError returns ErrDuplicateRoute if a route registered before rt has the same method,
+// pattern and matchers
+func (r *LambdaMux) duplicateError(rt *Route) error {
+	for _, pattern := range rt.patterns {
+		for _, other := range r.groups[rt.method+" "+pattern].routes {
+			if other == rt {
+				break
+			}
+			if other.err == nil && other.matcherKey() == rt.matcherKey() {
+				return fmt.Errorf("%w: %s is already registered", ErrDuplicateRoute, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// duplicateNameError returns ErrDuplicateRoute if a route registered before rt has the same name
+func (r *LambdaMux) duplicateNameError(rt *Route) error {
+	if rt.name == "" {
+		return nil
+	}
+	for _, other := range r.routes {
+		if other == rt {
+			break
+		}
+		if other.err == nil && other.name == rt.name {
+			return fmt.Errorf("%w: name %s is already used by %s", ErrDuplicateRoute, rt.name, other.pattern)
+		}
+	}
+	return nil
+}
+
+// URL returns the path of the route named name, with its params replaced by the values given as
+// name and value pairs, e.g. URL("user.show", "id", "42"). Values are escaped for use in a path.
+// For a route with optional params, the pattern with exactly the given params is used. An error is
+// returned if there is no route with the name, or if a param is missing, unknown or doesn't
+// satisfy its constraint.
+func (r *LambdaMux) URL(name string, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("lambdamux: route %s: odd number of param name and value pairs", name)
+	}
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		params[pairs[i]] = pairs[i+1]
+	}
+	for _, rt := range r.routes {
+		if rt.name == name && rt.err == nil {
+			path, err := rt.url(params)
+			if err != nil {
+				return "", fmt.Errorf("lambdamux: route %s: %w", name, err)
+			}
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("lambdamux: no route named %s", name)
+}
+
+// Compile makes the router read-only and returns the error returned by Validate. Once compiled,
+// registering a route, adding middleware or changing an option panics. The routes stay in the
+// tree they were registered in rather than being copied to a separate structure: looking up a
+// route never writes to the tree, so once nothing else can, Handle can be called concurrently.
+// A router that isn't compiled can still be changed after Handle is called.
+func (r *LambdaMux) Compile() error {
+	r.compiled.Store(true)
+	return r.Validate()
+}
+
+// MustCompile compiles the router and panics with the error returned by Validate if any route
+// could not be registered
+func (r *LambdaMux) MustCompile() {
+	if err := r.Compile(); err != nil {
+		panic(err)
+	}
+}
+
+// checkMutable panics if the router is compiled
+func (r *LambdaMux) checkMutable() {
+	if r.compiled.Load() {
+		panic("lambdamux: router can't be changed after it is compiled")
 	}
 }
 
-func (r *LambdaMux) addRoute(method, path string, handler HandlerFunc) {
-	fullPath := method + " " + path
-	r.tree.InsertWithHandler(fullPath, handler)
+// Method registers a new route for an arbitrary HTTP method with the given path, handler and route middleware
+func (r *LambdaMux) Method(method, path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute(strings.ToUpper(method), path, handler, middlewares...)
+}
+
+// GET registers a new GET route with the given path, handler and route middleware
+func (r *LambdaMux) GET(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("GET", path, handler, middlewares...)
 }
 
-// GET registers a new GET route with the given path and handler
-func (r *LambdaMux) GET(path string, handler HandlerFunc) {
-	r.addRoute("GET", path, handler)
+// POST registers a new POST route with the given path, handler and route middleware
+func (r *LambdaMux) POST(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("POST", path, handler, middlewares...)
 }
 
-// POST registers a new POST route with the given path and handler
-func (r *LambdaMux) POST(path string, handler HandlerFunc) {
-	r.addRoute("POST", path, handler)
+// PUT registers a new PUT route with the given path, handler and route middleware
+func (r *LambdaMux) PUT(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("PUT", path, handler, middlewares...)
 }
 
-// PUT registers a new PUT route with the given path and handler
-func (r *LambdaMux) PUT(path string, handler HandlerFunc) {
-	r.addRoute("PUT", path, handler)
+// DELETE registers a new DELETE route with the given path, handler and route middleware
+func (r *LambdaMux) DELETE(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("DELETE", path, handler, middlewares...)
 }
 
-// DELETE registers a new DELETE route with the given path and handler
-func (r *LambdaMux) DELETE(path string, handler HandlerFunc) {
-	r.addRoute("DELETE", path, handler)
+// PATCH registers a new PATCH route with the given path, handler and route middleware
+func (r *LambdaMux) PATCH(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("PATCH", path, handler, middlewares...)
 }
 
-// Handle processes the incoming API Gateway proxy request and returns the appropriate response
+// HEAD registers a new HEAD route with the given path, handler and route middleware.
+// HEAD requests without an explicit HEAD route are served by the GET route with the body stripped.
+func (r *LambdaMux) HEAD(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("HEAD", path, handler, middlewares...)
+}
+
+// OPTIONS registers a new OPTIONS route with the given path, handler and route middleware
+func (r *LambdaMux) OPTIONS(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute("OPTIONS", path, handler, middlewares...)
+}
+
+// ANY registers a new route matching every HTTP method with the given path, handler and route middleware.
+// Routes registered for a specific method take precedence.
+func (r *LambdaMux) ANY(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.addRoute(anyMethod, path, handler, middlewares...)
+}
+
+// Handle processes the incoming API Gateway proxy request and returns the appropriate response.
+// When the path matches a route registered only for other methods, a 405 response with an Allow
+// header listing those methods is returned instead of a 404.
+// If neither Validate nor Compile was called, the first call validates the routes and logs the
+// routes that could not be registered with slog, as they are not served.
 func (r *LambdaMux) Handle(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	path := req.HTTPMethod + " " + req.Path
-	node, params := r.tree.Search(path)
+	if !r.validated.Load() && r.validated.CompareAndSwap(false, true) {
+		if err := r.Validate(); err != nil {
+			slog.Error("Invalid routes are not served", "error", err)
+		}
+	}
+	resp, err := r.handler(ctx, req)
+	if err != nil && r.errorHandler != nil {
+		return r.errorHandler(ctx, req, err), nil
+	}
+	return resp, err
+}
+
+// maxParams is the number of params a pooled params slice holds without growing
+const maxParams = 16
+
+// paramsPool holds the slices the params of a request are matched into, so that matching a route
+// doesn't allocate
+var paramsPool = sync.Pool{
+	New: func() any {
+		params := make(radix.Params, 0, maxParams)
+		return &params
+	},
+}
+
+// putParams returns a params slice to the pool, dropping its values
+func putParams(params *radix.Params) {
+	*params = (*params)[:0]
+	paramsPool.Put(params)
+}
+
+// dispatch finds the route matching the request and calls its handler
+func (r *LambdaMux) dispatch(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	prefix := r.pathPrefix(req)
+	routePath := req.Path[len(prefix):]
+	if routePath == "" {
+		routePath = "/"
+	}
+	params := paramsPool.Get().(*radix.Params)
+	defer putParams(params)
+	node, path, redirectRequest := r.lookup(routePath, params)
+	if !hasRoute(node) {
+		return r.notFound(ctx, req)
+	}
+	if redirectRequest {
+		location := prefix + path
+		if !r.useRawPath {
+			// A decoded path may contain characters such as spaces or ? that must be escaped in a URL
+			location = (&url.URL{Path: location}).EscapedPath()
+		}
+		return redirect(req, location)
+	}
+	req.Path = path
+	// PathParameters is a new map owned by the handler, the pooled params are only used for matching
+	req.PathParameters = params.Map()
+	if r.useRawPath {
+		unescapeParams(req.PathParameters)
+	}
+
+	// Route groups return errNoMatchingRoute when the matchers of their routes reject the request
+	noMatch := false
+	if handler := node.Handler(req.HTTPMethod); handler != nil {
+		resp, err := handler(ctx, req)
+		if err != errNoMatchingRoute {
+			return resp, err
+		}
+		noMatch = true
+	}
 
-	if node != nil && node.Handler != nil {
-		req.PathParameters = params
-		return node.Handler(ctx, req)
+	if req.HTTPMethod == http.MethodHead {
+		if handler := node.Handler(http.MethodGet); handler != nil {
+			resp, err := handler(ctx, req)
+			if err != errNoMatchingRoute {
+				resp.Body = ""
+				resp.IsBase64Encoded = false
+				return resp, err
+			}
+			noMatch = true
+		}
 	}
 
+	if handler := node.Handler(anyMethod); handler != nil {
+		resp, err := handler(ctx, req)
+		if err != errNoMatchingRoute {
+			return resp, err
+		}
+		noMatch = true
+	}
+
+	// A route registered for the method that doesn't match the request isn't a different method
+	if noMatch {
+		return r.notFound(ctx, req)
+	}
+
+	resp, err := r.methodNotAllowed(ctx, req)
+	if _, ok := resp.Headers["Allow"]; !ok && err == nil {
+		headers := make(map[string]string, len(resp.Headers)+1)
+		for k, v := range resp.Headers {
+			headers[k] = v
+		}
+		headers["Allow"] = allowedMethods(node)
+		resp.Headers = headers
+	}
+	return resp, err
+}
+
+// defaultNotFound is the handler used when no NotFound handler is set
+func defaultNotFound(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusNotFound,
 		Body:       `{"error": "404 Not Found"}`,
 		Headers:    map[string]string{"Content-Type": "application/json"},
 	}, nil
 }
+
+// defaultMethodNotAllowed is the handler used when no MethodNotAllowed handler is set
+func defaultMethodNotAllowed(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusMethodNotAllowed,
+		Body:       `{"error": "405 Method Not Allowed"}`,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}, nil
+}
+
+// allowedMethods returns the value of the Allow header for the given node
+func allowedMethods(node *radix.Node) string {
+	methods := node.Methods()
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+		sort.Strings(methods)
+	}
+	return strings.Join(methods, ", ")
+}
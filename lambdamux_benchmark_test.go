@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/D-Andreev/lambdamux/internal/radix"
 	"github.com/aquasecurity/lmdrouter"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/assert"
@@ -440,3 +441,56 @@ func BenchmarkStandardLibrary(b *testing.B) {
 		assertResponse(b, resp, req)
 	}
 }
+
+// noopHandler responds without allocating, so that benchmarks using it measure the router alone
+func noopHandler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+}
+
+func setupLambdaMuxNoop() *LambdaMux {
+	router := NewLambdaMux()
+	for _, route := range routes {
+		router.addRoute(route.method, route.path, noopHandler)
+	}
+	router.MustCompile()
+	return router
+}
+
+func BenchmarkLambdaMuxStaticRoute(b *testing.B) {
+	router := setupLambdaMuxNoop()
+	req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/store/inventory"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, _ := router.Handle(context.Background(), req)
+		if resp.StatusCode != 200 {
+			b.Fatalf("unexpected status code %d", resp.StatusCode)
+		}
+	}
+}
+
+func BenchmarkLambdaMuxParamRoute(b *testing.B) {
+	router := setupLambdaMuxNoop()
+	req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/user/evebrown/review/6060"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, _ := router.Handle(context.Background(), req)
+		if resp.StatusCode != 200 {
+			b.Fatalf("unexpected status code %d", resp.StatusCode)
+		}
+	}
+}
+
+func BenchmarkLambdaMuxMatch(b *testing.B) {
+	router := setupLambdaMuxNoop()
+	params := make(radix.Params, 0, maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := benchmarkRequests[i%len(benchmarkRequests)]
+		if node, _, _ := router.lookup(req.Path, &params); node == nil {
+			b.Fatalf("no route for %s", req.Path)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package lambdamux
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/D-Andreev/lambdamux/internal/radix"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/assert"
 )
@@ -32,6 +37,7 @@ func TestRouter(t *testing.T) {
 	router.GET("/user/:username", createHandler("GET", "/user/:username"))
 	router.PUT("/user/:username", createHandler("PUT", "/user/:username"))
 	router.DELETE("/user/:username", createHandler("DELETE", "/user/:username"))
+	assert.NoError(t, router.Validate())
 
 	testCases := []struct {
 		id             int
@@ -293,3 +299,651 @@ func createHandler(method, path string) HandlerFunc {
 		}, nil
 	}
 }
+
+func TestRouterMethods(t *testing.T) {
+	router := NewLambdaMux()
+
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+	router.PATCH("/pet/:petId", createHandler("PATCH", "/pet/:petId"))
+	router.OPTIONS("/pet/:petId", createHandler("OPTIONS", "/pet/:petId"))
+	router.GET("/store/inventory", createHandler("GET", "/store/inventory"))
+	router.HEAD("/store/inventory", createHandler("HEAD", "/store/inventory"))
+	router.Method("purge", "/store/cache", createHandler("PURGE", "/store/cache"))
+	router.ANY("/proxy/:target", createHandler("ANY", "/proxy/:target"))
+	router.POST("/proxy/:target", createHandler("POST", "/proxy/:target"))
+
+	testCases := []struct {
+		id              int
+		name            string
+		method          string
+		path            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{1, "PATCH route", "PATCH", "/pet/1", 200, "Handled PATCH request for /pet/:petId"},
+		{2, "OPTIONS route", "OPTIONS", "/pet/1", 200, "Handled OPTIONS request for /pet/:petId"},
+		{3, "explicit HEAD route", "HEAD", "/store/inventory", 200, "Handled HEAD request for /store/inventory"},
+		{4, "HEAD falls back to GET", "HEAD", "/pet/1", 200, ""},
+		{5, "custom method", "PURGE", "/store/cache", 200, "Handled PURGE request for /store/cache"},
+		{6, "ANY route", "PUT", "/proxy/users", 200, "Handled ANY request for /proxy/:target"},
+		{7, "ANY route with another method", "GET", "/proxy/users", 200, "Handled ANY request for /proxy/:target"},
+		{8, "specific method takes precedence over ANY", "POST", "/proxy/users", 200, "Handled POST request for /proxy/:target"},
+		{9, "unregistered method", "PUT", "/store/cache", 405, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus != 200 {
+				return
+			}
+			if tc.expectedMessage == "" {
+				assert.Empty(t, resp.Body, "Test case %d: %s - Expected empty body", tc.id, tc.name)
+				assert.Equal(t, "application/json", resp.Headers["Content-Type"], "Test case %d: %s - Header mismatch", tc.id, tc.name)
+				return
+			}
+
+			var bodyMap map[string]interface{}
+			err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+			assert.NoError(t, err, "Test case %d: %s - Failed to unmarshal response body", tc.id, tc.name)
+			assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d: %s - Message mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	router := NewLambdaMux()
+
+	router.GET("/pet/:petId", createHandler("GET", "/pet/:petId"))
+	router.POST("/pet/:petId", createHandler("POST", "/pet/:petId"))
+	router.DELETE("/pet/:petId", createHandler("DELETE", "/pet/:petId"))
+	router.POST("/store/order", createHandler("POST", "/store/order"))
+	router.GET("/store/order/:orderId", createHandler("GET", "/store/order/:orderId"))
+	router.ANY("/proxy", createHandler("ANY", "/proxy"))
+
+	testCases := []struct {
+		id             int
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{1, "param route", "PUT", "/pet/123", 405, "DELETE, GET, HEAD, POST"},
+		{2, "static route", "GET", "/store/order", 405, "POST"},
+		{3, "static route with param sibling", "PUT", "/store/order/1", 405, "GET, HEAD"},
+		{4, "ANY route never returns 405", "PUT", "/proxy", 200, ""},
+		{5, "unknown path", "PUT", "/store", 404, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedAllow, resp.Headers["Allow"], "Test case %d: %s - Allow header mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 405 {
+				assert.JSONEq(t, `{"error": "405 Method Not Allowed"}`, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestRouterFallbackHandlers(t *testing.T) {
+	router := NewLambdaMux()
+
+	problem := func(status int, title string) events.APIGatewayProxyResponse {
+		return events.APIGatewayProxyResponse{
+			StatusCode: status,
+			Body:       fmt.Sprintf(`{"status": %d, "title": %q}`, status, title),
+			Headers:    map[string]string{"Content-Type": "application/problem+json"},
+		}
+	}
+	router.NotFound(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return problem(404, "No route for "+req.Path), nil
+	})
+	router.MethodNotAllowed(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return problem(405, req.HTTPMethod+" is not allowed"), nil
+	})
+	router.ErrorHandler(func(ctx context.Context, req events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+		return problem(500, err.Error())
+	})
+
+	router.GET("/pet/:petId", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("pet %s is unavailable", req.PathParameters["petId"])
+	})
+
+	testCases := []struct {
+		id             int
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		expectedAllow  string
+	}{
+		{1, "not found", "GET", "/nonexistent", 404, `{"status": 404, "title": "No route for /nonexistent"}`, ""},
+		{2, "method not allowed", "PUT", "/pet/1", 405, `{"status": 405, "title": "PUT is not allowed"}`, "GET, HEAD"},
+		{3, "handler error", "GET", "/pet/1", 500, `{"status": 500, "title": "pet 1 is unavailable"}`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			assert.JSONEq(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			assert.Equal(t, "application/problem+json", resp.Headers["Content-Type"], "Test case %d: %s - Header mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedAllow, resp.Headers["Allow"], "Test case %d: %s - Allow header mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestRouterErrorHandlerRequest(t *testing.T) {
+	router := NewLambdaMux()
+	router.BasePath("/api")
+	router.ErrorHandler(func(ctx context.Context, req events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+		body, _ := json.Marshal(map[string]interface{}{"detail": err.Error(), "instance": req.Path, "params": req.PathParameters})
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: string(body)}
+	})
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if req.Headers["Authorization"] == "" {
+				return events.APIGatewayProxyResponse{}, fmt.Errorf("unauthorized")
+			}
+			return next(ctx, req)
+		}
+	})
+	router.GET("/pets/:petId", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("pet %s is unavailable", req.PathParameters["petId"])
+	})
+
+	testCases := []struct {
+		id           int
+		name         string
+		headers      map[string]string
+		expectedBody string
+	}{
+		{
+			1,
+			"handler error",
+			map[string]string{"Authorization": "token"},
+			`{"detail": "pet 1 is unavailable", "instance": "/pets/1", "params": {"petId": "1"}}`,
+		},
+		{2, "router middleware error", nil, `{"detail": "unauthorized", "instance": "/api/pets/1", "params": null}`},
+	}
+
+	for _, tc := range testCases {
+		req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/api/pets/1", Headers: tc.headers}
+		resp, err := router.Handle(context.Background(), req)
+
+		assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		assert.Equal(t, 500, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+		assert.JSONEq(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+	}
+}
+
+func TestRouterHandlerErrorWithoutErrorHandler(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/fail", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, fmt.Errorf("fail")
+	})
+
+	_, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/fail"})
+
+	assert.EqualError(t, err, "fail")
+}
+
+func TestRouterWildcard(t *testing.T) {
+	router := NewLambdaMux()
+
+	router.GET("/static/*filepath", createHandler("GET", "/static/*filepath"))
+	router.GET("/static/:version/manifest.json", createHandler("GET", "/static/:version/manifest.json"))
+	router.GET("/static/robots.txt", createHandler("GET", "/static/robots.txt"))
+	router.ANY("/api/{proxy+}", createHandler("ANY", "/api/{proxy+}"))
+
+	testCases := []struct {
+		id              int
+		name            string
+		method          string
+		path            string
+		expectedStatus  int
+		expectedMessage string
+		expectedParams  map[string]interface{}
+	}{
+		{
+			1,
+			"wildcard route",
+			"GET",
+			"/static/js/app/main.js",
+			200,
+			"Handled GET request for /static/*filepath",
+			map[string]interface{}{"filepath": "js/app/main.js"},
+		},
+		{2, "static route takes precedence", "GET", "/static/robots.txt", 200, "Handled GET request for /static/robots.txt", nil},
+		{
+			3,
+			"param route takes precedence",
+			"GET",
+			"/static/v2/manifest.json",
+			200,
+			"Handled GET request for /static/:version/manifest.json",
+			map[string]interface{}{"version": "v2"},
+		},
+		{
+			4,
+			"API Gateway style proxy route",
+			"POST",
+			"/api/orders/123/items",
+			200,
+			"Handled ANY request for /api/{proxy+}",
+			map[string]interface{}{"proxy": "orders/123/items"},
+		},
+		{5, "wildcard doesn't match other methods", "POST", "/static/js/app.js", 405, "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus != 200 {
+				return
+			}
+
+			var bodyMap map[string]interface{}
+			err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+			assert.NoError(t, err, "Test case %d: %s - Failed to unmarshal response body", tc.id, tc.name)
+			assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d: %s - Message mismatch", tc.id, tc.name)
+			if tc.expectedParams != nil {
+				assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d: %s - Params mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestRouterBraceParams(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/pet/{petId}", createHandler("GET", "/pet/{petId}"))
+	router.POST("/pet/:petId/uploadImage", createHandler("POST", "/pet/:petId/uploadImage"))
+	router.DELETE("/store/order/{orderId}", createHandler("DELETE", "/store/order/{orderId}"))
+
+	testCases := []struct {
+		id             int
+		method         string
+		path           string
+		expectedParams map[string]interface{}
+	}{
+		{1, "GET", "/pet/123", map[string]interface{}{"petId": "123"}},
+		{2, "POST", "/pet/123/uploadImage", map[string]interface{}{"petId": "123"}},
+		{3, "DELETE", "/store/order/9", map[string]interface{}{"orderId": "9"}},
+	}
+
+	for _, tc := range testCases {
+		req := events.APIGatewayProxyRequest{HTTPMethod: tc.method, Path: tc.path}
+		resp, err := router.Handle(context.Background(), req)
+
+		assert.NoError(t, err, "Test case %d - Unexpected error", tc.id)
+		assert.Equal(t, 200, resp.StatusCode, "Test case %d - Status code mismatch", tc.id)
+		var bodyMap map[string]interface{}
+		err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+		assert.NoError(t, err, "Test case %d - Failed to unmarshal response body", tc.id)
+		assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d - Params mismatch", tc.id)
+	}
+}
+
+func TestRouterValidate(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users/:id", createHandler("GET", "/users/:id"))
+	router.GET("/users/{id}", createHandler("GET", "/users/{id}"))
+	router.DELETE("/users/:userId", createHandler("DELETE", "/users/:userId"))
+	router.ANY("/static/*filepath/edit", createHandler("ANY", "/static/*filepath/edit"))
+	router.POST("users", createHandler("POST", "users"))
+	router.Route("/admin").GET("/:id/:id", createHandler("GET", "/admin/:id/:id"))
+
+	err := router.Validate()
+
+	assert.ErrorIs(t, err, ErrDuplicateRoute)
+	assert.ErrorIs(t, err, ErrRouteConflict)
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+	assert.EqualError(t, err, `lambdamux: 5 invalid route(s):
+GET /users/{id}: duplicate route: /users/:id is already registered
+DELETE /users/:userId: conflicting route: param :userId conflicts with :id at /users/
+ANY /static/*filepath/edit: invalid route pattern: wildcard *filepath must be the last segment
+POST users: invalid route pattern: path must begin with /
+GET /admin/:id/:id: invalid route pattern: duplicate param name id`)
+	assert.PanicsWithError(t, err.Error(), router.MustCompile)
+
+	// Valid routes are still served and the first registration of a duplicate route wins
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"message":"Handled GET request for /users/:id","params":{"id":"1"}}`, resp.Body)
+	resp, err = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/users/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 405, resp.StatusCode)
+}
+
+func TestRouterValidateWithoutErrors(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users/:id", createHandler("GET", "/users/:id"))
+	router.DELETE("/users/{id}", createHandler("DELETE", "/users/{id}"))
+
+	assert.NoError(t, router.Validate())
+	assert.NotPanics(t, router.MustCompile)
+}
+
+func TestRouterHandleLogsInvalidRoutes(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	testCases := []struct {
+		id       int
+		name     string
+		validate bool
+		expected int
+	}{
+		{1, "not validated", false, 1},
+		{2, "validated", true, 0},
+	}
+
+	for _, tc := range testCases {
+		logs.Reset()
+		router := NewLambdaMux()
+		router.GET("/users/:id", createHandler("GET", "/users/:id"))
+		router.DELETE("/users/:userId", createHandler("DELETE", "/users/:userId"))
+		if tc.validate {
+			assert.Error(t, router.Validate(), "Test case %d: %s - Expected an error", tc.id, tc.name)
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1"})
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+		}
+		assert.Equal(t, tc.expected, strings.Count(logs.String(), "Invalid routes are not served"), "Test case %d: %s - Log count mismatch", tc.id, tc.name)
+		if tc.expected > 0 {
+			assert.Contains(t, logs.String(), "DELETE /users/:userId: conflicting route", "Test case %d: %s - Log mismatch", tc.id, tc.name)
+		}
+	}
+}
+
+func TestRouterMultipleParamsInSegment(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/files/:name.:ext", createHandler("GET", "/files/:name.:ext"))
+	router.GET("/reports/{from}-{to}", createHandler("GET", "/reports/{from}-{to}"))
+	router.GET("/files/:name", createHandler("GET", "/files/:name"))
+	assert.NoError(t, router.Validate())
+
+	testCases := []struct {
+		id              int
+		path            string
+		expectedMessage string
+		expectedParams  map[string]interface{}
+	}{
+		{1, "/files/report.pdf", "Handled GET request for /files/:name.:ext", map[string]interface{}{"name": "report", "ext": "pdf"}},
+		{2, "/files/archive.tar.gz", "Handled GET request for /files/:name.:ext", map[string]interface{}{"name": "archive.tar", "ext": "gz"}},
+		{3, "/files/README", "Handled GET request for /files/:name", map[string]interface{}{"name": "README"}},
+		{4, "/reports/2024-2025", "Handled GET request for /reports/{from}-{to}", map[string]interface{}{"from": "2024", "to": "2025"}},
+	}
+
+	for _, tc := range testCases {
+		resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path})
+
+		assert.NoError(t, err, "Test case %d - Unexpected error", tc.id)
+		assert.Equal(t, 200, resp.StatusCode, "Test case %d - Status code mismatch", tc.id)
+		var bodyMap map[string]interface{}
+		err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+		assert.NoError(t, err, "Test case %d - Failed to unmarshal response body", tc.id)
+		assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d - Message mismatch", tc.id)
+		assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d - Params mismatch", tc.id)
+	}
+}
+
+func TestRouterOptionalParams(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/items/:id?", createHandler("GET", "/items/:id?"))
+	router.GET("/search/{term?}", createHandler("GET", "/search/{term?}"))
+	assert.NoError(t, router.Validate())
+
+	testCases := []struct {
+		id              int
+		path            string
+		expectedMessage string
+		expectedParams  interface{}
+	}{
+		{1, "/items", "Handled GET request for /items/:id?", nil},
+		{2, "/items/42", "Handled GET request for /items/:id?", map[string]interface{}{"id": "42"}},
+		{3, "/search", "Handled GET request for /search/{term?}", nil},
+		{4, "/search/lambda", "Handled GET request for /search/{term?}", map[string]interface{}{"term": "lambda"}},
+	}
+
+	for _, tc := range testCases {
+		resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path})
+
+		assert.NoError(t, err, "Test case %d - Unexpected error", tc.id)
+		assert.Equal(t, 200, resp.StatusCode, "Test case %d - Status code mismatch", tc.id)
+		var bodyMap map[string]interface{}
+		err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+		assert.NoError(t, err, "Test case %d - Failed to unmarshal response body", tc.id)
+		assert.Equal(t, tc.expectedMessage, bodyMap["message"], "Test case %d - Message mismatch", tc.id)
+		assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d - Params mismatch", tc.id)
+	}
+
+	duplicates := NewLambdaMux()
+	duplicates.GET("/items/:id?", createHandler("GET", "/items/:id?"))
+	duplicates.GET("/items", createHandler("GET", "/items"))
+	assert.ErrorIs(t, duplicates.Validate(), ErrDuplicateRoute)
+}
+
+func TestRouterCompile(t *testing.T) {
+	testCases := []struct {
+		id     int
+		name   string
+		change func(r *LambdaMux)
+	}{
+		{1, "route", func(r *LambdaMux) { r.GET("/orders", createHandler("GET", "/orders")) }},
+		{2, "group route", func(r *LambdaMux) {
+			r.Group("/admin", func(g *Group) { g.GET("/users", createHandler("GET", "/admin/users")) })
+		}},
+		{3, "mount", func(r *LambdaMux) { r.Mount("/v2", NewLambdaMux()) }},
+		{4, "middleware", func(r *LambdaMux) { r.Use(func(next HandlerFunc) HandlerFunc { return next }) }},
+		{5, "not found handler", func(r *LambdaMux) { r.NotFound(defaultNotFound) }},
+		{6, "option", func(r *LambdaMux) { r.TrailingSlash(TrailingSlashRedirect) }},
+		{7, "route matcher", func(r *LambdaMux) { r.routes[0].Header("Accept", "application/json") }},
+		{8, "route name", func(r *LambdaMux) { r.routes[0].Name("users") }},
+		{9, "group middleware", func(r *LambdaMux) { r.Route("/admin").Use(func(next HandlerFunc) HandlerFunc { return next }) }},
+	}
+
+	for _, tc := range testCases {
+		router := NewLambdaMux()
+		router.GET("/users", createHandler("GET", "/users"))
+		assert.NoError(t, router.Compile(), "Test case %d: %s - Unexpected error", tc.id, tc.name)
+
+		assert.PanicsWithValue(
+			t, "lambdamux: router can't be changed after it is compiled", func() { tc.change(router) },
+			"Test case %d: %s - Expected a panic", tc.id, tc.name,
+		)
+	}
+
+	router := NewLambdaMux()
+	router.GET("/users", createHandler("GET", "/users"))
+	router.GET("/users", createHandler("GET", "/users"))
+	assert.ErrorIs(t, router.Compile(), ErrDuplicateRoute)
+	assert.Panics(t, router.MustCompile)
+
+	router = NewLambdaMux()
+	router.GET("/users", createHandler("GET", "/users"))
+	_, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users"})
+	assert.NoError(t, err)
+	// Only Compile freezes the router, routes can still be registered after Handle is called
+	assert.NotPanics(t, func() { router.GET("/orders", createHandler("GET", "/orders")) })
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/orders"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestRouterConcurrentHandle(t *testing.T) {
+	router := NewLambdaMux()
+	router.Use(func(next HandlerFunc) HandlerFunc { return next })
+	router.CaseMatching(CaseInsensitive)
+	router.TrailingSlash(TrailingSlashTolerant)
+	router.GET("/users", createHandler("GET", "/users"))
+	router.GET("/users/:id<int>", createHandler("GET", "/users/:id<int>"))
+	router.GET("/users/:id", createHandler("GET", "/users/:id")).Header("Accept", "application/vnd.acme.v2+json")
+	router.GET("/files/:name.:ext", createHandler("GET", "/files/:name.:ext"))
+	router.GET("/static/*filepath", createHandler("GET", "/static/*filepath"))
+	router.GET("/tenants", createHandler("GET", "/tenants")).Host("{tenant}.example.com")
+
+	requests := []struct {
+		req            events.APIGatewayProxyRequest
+		expectedStatus int
+		expectedBody   string
+	}{
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users"}, 200, `{"message":"Handled GET request for /users"}`},
+		{
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/USERS/42/"},
+			200,
+			`{"message":"Handled GET request for /users/:id\u003cint\u003e","params":{"id":"42"}}`,
+		},
+		{
+			events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Path:       "/users/alice",
+				Headers:    map[string]string{"Accept": "application/vnd.acme.v2+json"},
+			},
+			200,
+			`{"message":"Handled GET request for /users/:id","params":{"id":"alice"}}`,
+		},
+		{
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/files/report.pdf"},
+			200,
+			`{"message":"Handled GET request for /files/:name.:ext","params":{"ext":"pdf","name":"report"}}`,
+		},
+		{
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/static/css/app.css"},
+			200,
+			`{"message":"Handled GET request for /static/*filepath","params":{"filepath":"css/app.css"}}`,
+		},
+		{
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/tenants", Headers: map[string]string{"Host": "acme.example.com"}},
+			200,
+			`{"message":"Handled GET request for /tenants","params":{"tenant":"acme"}}`,
+		},
+		{events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/users"}, 405, `{"error": "405 Method Not Allowed"}`},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/orders"}, 404, `{"error": "404 Not Found"}`},
+	}
+
+	// Run with -race to detect data races between concurrent lookups
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tc := requests[(g+i)%len(requests)]
+				resp, err := router.Handle(context.Background(), tc.req)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Status code mismatch for %s", tc.req.Path)
+				assert.Equal(t, tc.expectedBody, resp.Body, "Body mismatch for %s", tc.req.Path)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestRouterAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations aren't stable with the race detector")
+	}
+	router := NewLambdaMux()
+	// The status code reports the number of path parameters without allocating
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200 + len(req.PathParameters)}, nil
+	}
+	router.GET("/users", handler)
+	router.GET("/users/:id", handler)
+	router.GET("/users/:id/orders/:orderId", handler)
+	router.GET("/static/*filepath", handler)
+	router.MustCompile()
+
+	testCases := []struct {
+		id     int
+		path   string
+		params int
+	}{
+		{1, "/users", 0},
+		{2, "/users/42", 1},
+		{3, "/users/42/orders/7", 2},
+		{4, "/static/css/app.css", 1},
+	}
+
+	for _, tc := range testCases {
+		req := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path}
+		params := make(radix.Params, 0, maxParams)
+		matchAllocs := testing.AllocsPerRun(100, func() {
+			router.lookup(tc.path, &params)
+		})
+		assert.Equal(t, float64(0), matchAllocs, "Test case %d - Matching allocated", tc.id)
+		assert.Len(t, params, tc.params, "Test case %d - Params mismatch", tc.id)
+
+		// Handle allocates the PathParameters map passed to the handler and nothing else
+		var pathParameters map[string]string
+		mapAllocs := testing.AllocsPerRun(100, func() {
+			pathParameters = params.Map()
+		})
+		assert.Len(t, pathParameters, tc.params, "Test case %d - PathParameters mismatch", tc.id)
+		var resp events.APIGatewayProxyResponse
+		handleAllocs := testing.AllocsPerRun(100, func() {
+			resp, _ = router.Handle(context.Background(), req)
+		})
+		assert.Equal(t, 200+tc.params, resp.StatusCode, "Test case %d - PathParameters mismatch", tc.id)
+		assert.Equal(t, mapAllocs, handleAllocs, "Test case %d - Handle allocations mismatch", tc.id)
+	}
+}
+
+func TestRouterPathParametersOwnedByHandler(t *testing.T) {
+	router := NewLambdaMux()
+	var kept []map[string]string
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		req.PathParameters["seen"] = "true"
+		kept = append(kept, req.PathParameters)
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	router.GET("/users", handler)
+	router.GET("/users/:id", handler)
+
+	for _, path := range []string{"/users/1", "/users/2", "/users"} {
+		_, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: path})
+		assert.NoError(t, err)
+	}
+
+	// Every handler gets its own map, which keeps its values once Handle returns
+	assert.Equal(t, []map[string]string{
+		{"id": "1", "seen": "true"},
+		{"id": "2", "seen": "true"},
+		{"seen": "true"},
+	}, kept)
+}
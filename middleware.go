@@ -0,0 +1,22 @@
+package lambdamux
+
+// MiddlewareFunc wraps a handler to run code before and after it
+type MiddlewareFunc func(HandlerFunc) HandlerFunc
+
+// Use appends middleware to the router. Router middleware wraps every request, including those
+// answered by the NotFound and MethodNotAllowed handlers, and runs before route matching, so
+// PathParameters are not yet populated. Middleware runs in the order it was added, before any
+// route middleware.
+func (r *LambdaMux) Use(middlewares ...MiddlewareFunc) {
+	r.checkMutable()
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.handler = chain(r.dispatch, r.middlewares)
+}
+
+// chain wraps handler with the given middleware so that the first middleware is the outermost
+func chain(handler HandlerFunc, middlewares []MiddlewareFunc) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,154 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMiddleware appends name to calls before and after calling the next handler
+func recordingMiddleware(name string, calls *[]string) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			*calls = append(*calls, "before "+name)
+			resp, err := next(ctx, req)
+			*calls = append(*calls, "after "+name)
+			return resp, err
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	router := NewLambdaMux()
+	router.Use(recordingMiddleware("router 1", &calls), recordingMiddleware("router 2", &calls))
+	router.GET(
+		"/pet/:petId",
+		func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			calls = append(calls, "handler "+req.PathParameters["petId"])
+			return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+		},
+		recordingMiddleware("route 1", &calls),
+		recordingMiddleware("route 2", &calls),
+	)
+	router.POST("/store/order", createHandler("POST", "/store/order"))
+	// Router middleware added after routes are registered still applies to them
+	router.Use(recordingMiddleware("router 3", &calls))
+
+	testCases := []struct {
+		id             int
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedCalls  []string
+	}{
+		{
+			1,
+			"router and route middleware",
+			"GET",
+			"/pet/123",
+			200,
+			[]string{
+				"before router 1", "before router 2", "before router 3", "before route 1", "before route 2",
+				"handler 123",
+				"after route 2", "after route 1", "after router 3", "after router 2", "after router 1",
+			},
+		},
+		{
+			2,
+			"route without route middleware",
+			"POST",
+			"/store/order",
+			200,
+			[]string{
+				"before router 1", "before router 2", "before router 3",
+				"after router 3", "after router 2", "after router 1",
+			},
+		},
+		{
+			3,
+			"not found",
+			"GET",
+			"/nonexistent",
+			404,
+			[]string{
+				"before router 1", "before router 2", "before router 3",
+				"after router 3", "after router 2", "after router 1",
+			},
+		},
+		{
+			4,
+			"method not allowed",
+			"PUT",
+			"/pet/123",
+			405,
+			[]string{
+				"before router 1", "before router 2", "before router 3",
+				"after router 3", "after router 2", "after router 1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			calls = nil
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedCalls, calls, "Test case %d: %s - Middleware order mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestMiddlewareModifiesResponse(t *testing.T) {
+	cors := func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			resp, err := next(ctx, req)
+			if resp.Headers == nil {
+				resp.Headers = map[string]string{}
+			}
+			resp.Headers["Access-Control-Allow-Origin"] = "*"
+			return resp, err
+		}
+	}
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if req.Headers["Authorization"] == "" {
+				return events.APIGatewayProxyResponse{StatusCode: 401}, nil
+			}
+			return next(ctx, req)
+		}
+	}
+
+	router := NewLambdaMux()
+	router.Use(cors)
+	router.GET("/admin", createHandler("GET", "/admin"), auth)
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "*", resp.Headers["Access-Control-Allow-Origin"])
+
+	resp, err = router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/admin",
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "*", resp.Headers["Access-Control-Allow-Origin"])
+
+	resp, err = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/nonexistent"})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "*", resp.Headers["Access-Control-Allow-Origin"])
+}
@@ -0,0 +1,56 @@
+package lambdamux
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Mount registers the routes of sub under prefix. The prefix may contain path parameters.
+// The sub-router's root route is registered at prefix itself. Requests are handled by the sub-router's routes with req.Path rewritten relative to prefix,
+// and pass through the middleware of both routers: the router's middleware first, then the
+// middleware of sub. Mount compiles sub, so registering routes, adding middleware or changing
+// options on sub afterwards panics instead of being silently left out. The router's own NotFound
+// and MethodNotAllowed handlers are used for unmatched requests.
+func (r *LambdaMux) Mount(prefix string, sub *LambdaMux) {
+	r.checkMutable()
+	strip := stripPrefix(strings.Count(strings.TrimSuffix(prefix, "/"), "/"))
+	for _, rt := range sub.routes {
+		middlewares := make([]MiddlewareFunc, 0, 1+len(sub.middlewares)+len(rt.middlewares))
+		middlewares = append(middlewares, strip)
+		middlewares = append(middlewares, sub.middlewares...)
+		middlewares = append(middlewares, rt.middlewares...)
+		path := joinPaths(prefix, rt.pattern)
+		if rt.pattern == "/" && strings.TrimSuffix(prefix, "/") != "" {
+			path = strings.TrimSuffix(prefix, "/")
+		}
+		copied := r.addRoute(rt.method, path, rt.handler, middlewares...)
+		copied.matchers = append([]requestMatcher(nil), rt.matchers...)
+		copied.name = rt.name
+		copied.mounted = true
+	}
+	sub.compiled.Store(true)
+}
+
+// stripPrefix returns middleware that removes the first segments path segments from req.Path
+func stripPrefix(segments int) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			path := req.Path
+			for i := 0; i < segments && path != ""; i++ {
+				idx := strings.IndexByte(path[1:], '/')
+				if idx < 0 {
+					path = ""
+					break
+				}
+				path = path[idx+1:]
+			}
+			if path == "" {
+				path = "/"
+			}
+			req.Path = path
+			return next(ctx, req)
+		}
+	}
+}
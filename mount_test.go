@@ -0,0 +1,143 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// pathHandler responds with the path and path parameters seen by the handler
+func pathHandler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"path": req.Path, "params": req.PathParameters})
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: string(body)}, nil
+}
+
+func TestMount(t *testing.T) {
+	var calls []string
+
+	users := NewLambdaMux()
+	users.Use(recordingMiddleware("users", &calls))
+	users.GET("/", pathHandler)
+	users.GET("/:id", pathHandler, recordingMiddleware("route", &calls))
+	users.POST("/:id/roles", pathHandler)
+
+	billing := NewLambdaMux()
+	billing.GET("/invoices/:invoiceId", pathHandler)
+
+	router := NewLambdaMux()
+	router.Use(recordingMiddleware("router", &calls))
+	router.GET("/health", pathHandler)
+	router.Mount("/users", users)
+	router.Mount("/tenants/:tenantId/billing/", billing)
+
+	testCases := []struct {
+		id             int
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedPath   string
+		expectedParams map[string]interface{}
+		expectedCalls  []string
+	}{
+		{1, "parent route", "GET", "/health", 200, "/health", nil, []string{"router"}},
+		{2, "mounted root route", "GET", "/users", 200, "/", nil, []string{"router", "users"}},
+		{
+			3,
+			"mounted param route",
+			"GET",
+			"/users/42",
+			200,
+			"/42",
+			map[string]interface{}{"id": "42"},
+			[]string{"router", "users", "route"},
+		},
+		{
+			4,
+			"mounted nested route",
+			"POST",
+			"/users/42/roles",
+			200,
+			"/42/roles",
+			map[string]interface{}{"id": "42"},
+			[]string{"router", "users"},
+		},
+		{
+			5,
+			"prefix with params",
+			"GET",
+			"/tenants/acme/billing/invoices/7",
+			200,
+			"/invoices/7",
+			map[string]interface{}{"tenantId": "acme", "invoiceId": "7"},
+			[]string{"router"},
+		},
+		{6, "method not allowed on mounted route", "DELETE", "/users/42", 405, "", nil, []string{"router"}},
+		{7, "unmounted path", "GET", "/42", 404, "", nil, []string{"router"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			calls = nil
+			req := events.APIGatewayProxyRequest{
+				HTTPMethod: tc.method,
+				Path:       tc.path,
+			}
+			resp, err := router.Handle(context.Background(), req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+
+			var before []string
+			for i := 0; i < len(calls)/2; i++ {
+				before = append(before, calls[i][len("before "):])
+			}
+			assert.Equal(t, tc.expectedCalls, before, "Test case %d: %s - Middleware mismatch", tc.id, tc.name)
+
+			if tc.expectedStatus != 200 {
+				return
+			}
+			var bodyMap map[string]interface{}
+			err = json.Unmarshal([]byte(resp.Body), &bodyMap)
+			assert.NoError(t, err, "Test case %d: %s - Failed to unmarshal response body", tc.id, tc.name)
+			assert.Equal(t, tc.expectedPath, bodyMap["path"], "Test case %d: %s - Path mismatch", tc.id, tc.name)
+			if tc.expectedParams != nil {
+				assert.Equal(t, tc.expectedParams, bodyMap["params"], "Test case %d: %s - Params mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestMountCompilesSubRouter(t *testing.T) {
+	testCases := []struct {
+		id     int
+		name   string
+		change func(sub *LambdaMux)
+	}{
+		{1, "route", func(sub *LambdaMux) { sub.GET("/orders", createHandler("GET", "/orders")) }},
+		{2, "middleware", func(sub *LambdaMux) { sub.Use(func(next HandlerFunc) HandlerFunc { return next }) }},
+		{3, "option", func(sub *LambdaMux) { sub.TrailingSlash(TrailingSlashRedirect) }},
+		{4, "route matcher", func(sub *LambdaMux) { sub.routes[0].Header("Accept", "application/json") }},
+	}
+
+	for _, tc := range testCases {
+		sub := NewLambdaMux()
+		sub.GET("/:id", createHandler("GET", "/:id"))
+		router := NewLambdaMux()
+		router.Mount("/users", sub)
+
+		assert.PanicsWithValue(
+			t, "lambdamux: router can't be changed after it is compiled", func() { tc.change(sub) },
+			"Test case %d: %s - Expected a panic", tc.id, tc.name,
+		)
+		// The router the sub-router is mounted on can still be changed
+		assert.NotPanics(t, func() { router.GET("/health", createHandler("GET", "/health")) }, "Test case %d: %s - Unexpected panic", tc.id, tc.name)
+	}
+}
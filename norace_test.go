@@ -0,0 +1,7 @@
+//go:build !race
+
+package lambdamux
+
+// raceEnabled reports whether the tests run with the race detector, which makes sync.Pool drop
+// pooled values at random and so allocate
+const raceEnabled = false
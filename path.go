@@ -0,0 +1,276 @@
+package lambdamux
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/D-Andreev/lambdamux/internal/radix"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// TrailingSlashPolicy defines how a request path that only differs from a route by a trailing slash is handled
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashStrict only matches routes with the same trailing slash as the request path
+	TrailingSlashStrict TrailingSlashPolicy = iota
+	// TrailingSlashTolerant matches routes with or without a trailing slash
+	TrailingSlashTolerant
+	// TrailingSlashRedirect redirects to the path of the route with or without a trailing slash,
+	// with a 301 for GET and HEAD requests and a 308 for other methods
+	TrailingSlashRedirect
+)
+
+// TrailingSlash sets the trailing slash policy. The default is TrailingSlashStrict.
+func (r *LambdaMux) TrailingSlash(policy TrailingSlashPolicy) {
+	r.checkMutable()
+	r.trailingSlash = policy
+}
+
+// CleanPath sets whether the request path is cleaned before routing, removing duplicate slashes
+// and resolving . and .. segments. Handlers receive the cleaned req.Path. With TrailingSlashRedirect,
+// requests whose path isn't clean are redirected to the cleaned path instead.
+func (r *LambdaMux) CleanPath(enabled bool) {
+	r.checkMutable()
+	r.cleanPath = enabled
+}
+
+// UseRawPath sets whether req.Path is treated as percent-encoded. Routes are then matched against
+// the encoded path, so that an encoded slash (%2F) is part of a param value instead of separating
+// segments, and path params are decoded before they are passed to handlers. Values that aren't
+// valid percent-encoding are passed as is. Static parts of routes are compared with the encoded path.
+// HTTP API and Function URL events carry the encoded path, which is decoded before routing unless
+// UseRawPath is set, so that handlers get the same path and params as with Handle.
+func (r *LambdaMux) UseRawPath(enabled bool) {
+	r.checkMutable()
+	r.useRawPath = enabled
+}
+
+// decodeRawPath decodes the path of a request converted from an event that carries the
+// percent-encoded path, unless UseRawPath is set. A path that isn't valid percent-encoding is kept.
+func (r *LambdaMux) decodeRawPath(req events.APIGatewayProxyRequest) events.APIGatewayProxyRequest {
+	if r.useRawPath {
+		return req
+	}
+	req.Path = unescapePath(req.Path)
+	req.RequestContext.Path = unescapePath(req.RequestContext.Path)
+	return req
+}
+
+// unescapePath returns p with its percent-encoding decoded, or p as is if it isn't valid
+func unescapePath(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		return unescaped
+	}
+	return p
+}
+
+// unescapeParams decodes percent-encoded param values in place
+func unescapeParams(params map[string]string) {
+	for name, value := range params {
+		params[name] = unescapePath(value)
+	}
+}
+
+// BasePath sets a prefix, such as the base path mapping of a custom domain, that is removed from
+// req.Path before routing. Requests without the prefix are routed as is.
+func (r *LambdaMux) BasePath(prefix string) {
+	r.checkMutable()
+	r.basePath = strings.TrimSuffix(prefix, "/")
+}
+
+// StripStage sets whether the prefix API Gateway adds in front of the route path is removed from
+// req.Path before routing. This is the stage, taken from RequestContext.Stage, when the path
+// starts with it and RequestContext.Path is the same path, as in HTTP API requests to a named
+// stage. REST API requests carry the stage in RequestContext.Path only, so their path is kept even
+// if its first segment is the name of the stage. For REST API requests, it is the part of the path
+// in front of the path of the resource, such as /users/{id} or /{proxy+}, with its params filled in
+// from PathParameters, e.g. a base path mapping.
+func (r *LambdaMux) StripStage(enabled bool) {
+	r.checkMutable()
+	r.stripStage = enabled
+}
+
+// pathPrefix returns the prefix of req.Path that is removed before routing
+func (r *LambdaMux) pathPrefix(req events.APIGatewayProxyRequest) string {
+	prefix := ""
+	if r.stripStage {
+		if stage := req.RequestContext.Stage; stage != "" && stage != "$default" &&
+			req.RequestContext.Path == req.Path && hasPathPrefix(req.Path, "/"+stage) {
+			prefix = "/" + stage
+		} else {
+			prefix = resourcePrefix(req)
+		}
+	}
+	if r.basePath != "" && hasPathPrefix(req.Path[len(prefix):], r.basePath) {
+		prefix += r.basePath
+	}
+	return prefix
+}
+
+// resourcePrefix returns the part of req.Path in front of the path of the REST API resource, e.g.
+// /api for the resource /users/{id} with the path /api/users/1 and the id param 1, or for the
+// resource /{proxy+} with the path /api/users/1 and the proxy param users/1
+func resourcePrefix(req events.APIGatewayProxyRequest) string {
+	resourcePath, ok := resolveResource(req.Resource, req.PathParameters)
+	if !ok {
+		return ""
+	}
+	if resourcePath == "/" {
+		// The root resource, e.g. /api or /api/ for a base path mapping
+		return strings.TrimSuffix(req.Path, "/")
+	}
+	if !strings.HasSuffix(req.Path, resourcePath) || strings.HasSuffix(req.Path[:len(req.Path)-len(resourcePath)], "/") {
+		return ""
+	}
+	return req.Path[:len(req.Path)-len(resourcePath)]
+}
+
+// resolveResource returns the path of a REST API resource with its {name} and {name+} params
+// replaced by their values. It reports false if the resource isn't a path or a param is missing.
+func resolveResource(resource string, params map[string]string) (string, bool) {
+	if !strings.HasPrefix(resource, "/") {
+		return "", false
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(resource, '{')
+		if start < 0 {
+			b.WriteString(resource)
+			return b.String(), true
+		}
+		end := strings.IndexByte(resource[start:], '}')
+		if end < 0 {
+			return "", false
+		}
+		value, ok := params[strings.TrimSuffix(resource[start+1:start+end], "+")]
+		if !ok {
+			return "", false
+		}
+		b.WriteString(resource[:start])
+		b.WriteString(value)
+		resource = resource[start+end+1:]
+	}
+}
+
+// hasPathPrefix reports whether p is prefix or starts with prefix followed by a slash
+func hasPathPrefix(p, prefix string) bool {
+	return strings.HasPrefix(p, prefix) && (len(p) == len(prefix) || p[len(prefix)] == '/')
+}
+
+// CasePolicy defines how the case of static parts of the request path is matched
+type CasePolicy int
+
+const (
+	// CaseSensitive only matches routes with the same case as the request path
+	CaseSensitive CasePolicy = iota
+	// CaseInsensitive matches static parts of routes regardless of ASCII case when no route matches
+	// the exact case. Param values keep the case of the request path.
+	CaseInsensitive
+	// CaseRedirect matches like CaseInsensitive, but redirects to the path in the case of the route,
+	// with a 301 for GET and HEAD requests and a 308 for other methods
+	CaseRedirect
+)
+
+// CaseMatching sets the case policy. The default is CaseSensitive.
+func (r *LambdaMux) CaseMatching(policy CasePolicy) {
+	r.checkMutable()
+	r.casePolicy = policy
+}
+
+// lookup searches the tree for the request path according to the path cleaning, trailing slash and
+// case policies. It returns the matched node and the path of the request after cleaning, and
+// appends the params of the node to params. If the request should be redirected, the returned path
+// is the canonical path instead and redirect is true.
+func (r *LambdaMux) lookup(p string, params *radix.Params) (node *radix.Node, path string, redirect bool) {
+	path = p
+	if r.cleanPath {
+		path = cleanPath(p)
+		redirect = path != p && r.trailingSlash == TrailingSlashRedirect
+	}
+
+	candidates := [2]string{path}
+	if r.trailingSlash != TrailingSlashStrict && path != "/" && path != "" {
+		candidates[1] = path + "/"
+		if strings.HasSuffix(path, "/") {
+			candidates[1] = path[:len(path)-1]
+		}
+	}
+
+	for _, fold := range [2]bool{false, true} {
+		if fold && r.casePolicy == CaseSensitive {
+			break
+		}
+		for i, candidate := range candidates {
+			if candidate == "" {
+				continue
+			}
+			*params = (*params)[:0]
+			node = r.tree.Lookup(candidate, params, fold)
+			if !hasRoute(node) {
+				continue
+			}
+			switch {
+			case fold && r.casePolicy == CaseRedirect:
+				return node, node.Path(params.Map()), true
+			case i > 0 && r.trailingSlash == TrailingSlashRedirect:
+				return node, candidate, true
+			}
+			return node, path, redirect
+		}
+	}
+	*params = (*params)[:0]
+	return nil, path, false
+}
+
+// hasRoute reports whether node is the node of a registered route
+func hasRoute(node *radix.Node) bool {
+	return node != nil && node.HasHandlers()
+}
+
+// cleanPath returns the canonical form of p, removing duplicate slashes and resolving . and ..
+// segments. A trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// redirect returns a response redirecting the request to location, keeping its query string
+func redirect(req events.APIGatewayProxyRequest, location string) (events.APIGatewayProxyResponse, error) {
+	status := http.StatusPermanentRedirect
+	if req.HTTPMethod == http.MethodGet || req.HTTPMethod == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	if query := encodeQuery(req); query != "" {
+		location += "?" + query
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Location": location},
+	}, nil
+}
+
+// encodeQuery returns the query string of the request
+func encodeQuery(req events.APIGatewayProxyRequest) string {
+	values := url.Values{}
+	for k, v := range req.MultiValueQueryStringParameters {
+		values[k] = v
+	}
+	for k, v := range req.QueryStringParameters {
+		if _, ok := values[k]; !ok {
+			values.Set(k, v)
+		}
+	}
+	return values.Encode()
+}
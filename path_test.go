@@ -0,0 +1,470 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanPath(t *testing.T) {
+	testCases := []struct {
+		id       int
+		path     string
+		expected string
+	}{
+		{1, "", "/"},
+		{2, "/", "/"},
+		{3, "/users", "/users"},
+		{4, "/users/", "/users/"},
+		{5, "//users//123", "/users/123"},
+		{6, "/users/./123", "/users/123"},
+		{7, "/users/admin/../123", "/users/123"},
+		{8, "/../users", "/users"},
+		{9, "users/123/", "/users/123/"},
+		{10, "/users/..", "/"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, cleanPath(tc.path), fmt.Sprintf("Test case %d - Path mismatch", tc.id))
+	}
+}
+
+// pathEchoHandler returns the request path in the response body
+func pathEchoHandler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: req.Path}, nil
+}
+
+func TestRouterTrailingSlash(t *testing.T) {
+	testCases := []struct {
+		id               int
+		name             string
+		policy           TrailingSlashPolicy
+		method           string
+		path             string
+		query            map[string]string
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{1, "strict exact match", TrailingSlashStrict, "GET", "/users", nil, 200, "/users", ""},
+		{2, "strict extra slash", TrailingSlashStrict, "GET", "/users/", nil, 404, "", ""},
+		{3, "strict missing slash", TrailingSlashStrict, "GET", "/docs", nil, 404, "", ""},
+		{4, "tolerant extra slash", TrailingSlashTolerant, "GET", "/users/", nil, 200, "/users/", ""},
+		{5, "tolerant missing slash", TrailingSlashTolerant, "POST", "/docs", nil, 200, "/docs", ""},
+		{6, "tolerant exact match", TrailingSlashTolerant, "GET", "/docs/", nil, 200, "/docs/", ""},
+		{7, "tolerant no match", TrailingSlashTolerant, "GET", "/pets/", nil, 404, "", ""},
+		{8, "redirect GET", TrailingSlashRedirect, "GET", "/users/", nil, 301, "", "/users"},
+		{9, "redirect POST", TrailingSlashRedirect, "POST", "/docs", nil, 308, "", "/docs/"},
+		{10, "redirect keeps the query", TrailingSlashRedirect, "GET", "/users/1/", map[string]string{"q": "a b"}, 301, "", "/users/1?q=a+b"},
+		{11, "redirect exact match", TrailingSlashRedirect, "GET", "/users", nil, 200, "/users", ""},
+		{12, "redirect no match", TrailingSlashRedirect, "GET", "/pets/", nil, 404, "", ""},
+		{13, "redirect escapes the path", TrailingSlashRedirect, "GET", "/users/a b?c/", nil, 301, "", "/users/a%20b%3Fc"},
+		{14, "redirect escapes non-ASCII characters", TrailingSlashRedirect, "GET", "/users/é/", nil, 301, "", "/users/%C3%A9"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			router := NewLambdaMux()
+			router.TrailingSlash(tc.policy)
+			router.GET("/users", pathEchoHandler)
+			router.GET("/users/:id", pathEchoHandler)
+			router.GET("/docs/", pathEchoHandler)
+			router.POST("/docs/", pathEchoHandler)
+
+			resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            tc.method,
+				Path:                  tc.path,
+				QueryStringParameters: tc.query,
+			})
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			}
+			assert.Equal(t, tc.expectedLocation, resp.Headers["Location"], "Test case %d: %s - Location mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestRouterCleanPath(t *testing.T) {
+	testCases := []struct {
+		id               int
+		name             string
+		policy           TrailingSlashPolicy
+		path             string
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{1, "duplicate slashes", TrailingSlashStrict, "//users//42", 200, "/users/42", ""},
+		{2, "dot segments", TrailingSlashStrict, "/users/./admin/../42", 200, "/users/42", ""},
+		{3, "clean path", TrailingSlashStrict, "/users/42", 200, "/users/42", ""},
+		{4, "dot segments and trailing slash", TrailingSlashTolerant, "/users/./42/", 200, "/users/42/", ""},
+		{5, "redirect to the clean path", TrailingSlashRedirect, "//users/42", 301, "", "/users/42"},
+		{6, "redirect to the clean path without trailing slash", TrailingSlashRedirect, "/users/x/../42/", 301, "", "/users/42"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			router := NewLambdaMux()
+			router.CleanPath(true)
+			router.TrailingSlash(tc.policy)
+			router.GET("/users/:id", pathEchoHandler)
+
+			resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path})
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedLocation, resp.Headers["Location"], "Test case %d: %s - Location mismatch", tc.id, tc.name)
+		})
+	}
+
+	// Without CleanPath, the path is matched as is
+	router := NewLambdaMux()
+	router.GET("/users/:id", pathEchoHandler)
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "//users/42"})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestRouterCaseMatching(t *testing.T) {
+	testCases := []struct {
+		id               int
+		name             string
+		policy           CasePolicy
+		slashPolicy      TrailingSlashPolicy
+		method           string
+		path             string
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{1, "sensitive", CaseSensitive, TrailingSlashStrict, "GET", "/Users/AbC", 404, "", ""},
+		{2, "insensitive", CaseInsensitive, TrailingSlashStrict, "GET", "/Users/AbC", 200, "/Users/AbC AbC", ""},
+		{3, "exact case match first", CaseInsensitive, TrailingSlashStrict, "GET", "/users/Me", 200, "/users/Me Me", ""},
+		{4, "insensitive and tolerant", CaseInsensitive, TrailingSlashTolerant, "GET", "/USERS/AbC/", 200, "/USERS/AbC/ AbC", ""},
+		{5, "redirect GET", CaseRedirect, TrailingSlashStrict, "GET", "/USERS/AbC", 301, "", "/users/AbC"},
+		{6, "redirect DELETE", CaseRedirect, TrailingSlashStrict, "DELETE", "/USERS/AbC", 308, "", "/users/AbC"},
+		{7, "redirect with trailing slash", CaseRedirect, TrailingSlashRedirect, "GET", "/USERS/AbC/", 301, "", "/users/AbC"},
+		{8, "no redirect for exact case", CaseRedirect, TrailingSlashStrict, "GET", "/users/AbC", 200, "/users/AbC AbC", ""},
+		{9, "no match", CaseInsensitive, TrailingSlashStrict, "GET", "/pets/1", 404, "", ""},
+		{10, "redirect escapes the path", CaseRedirect, TrailingSlashStrict, "GET", "/USERS/a b?c", 301, "", "/users/a%20b%3Fc"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			router := NewLambdaMux()
+			router.CaseMatching(tc.policy)
+			router.TrailingSlash(tc.slashPolicy)
+			handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: 200, Body: req.Path + " " + req.PathParameters["id"]}, nil
+			}
+			router.GET("/users/:id", handler)
+			router.DELETE("/users/:id", handler)
+			router.GET("/users/me", func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: 200, Body: req.Path + " me"}, nil
+			})
+
+			resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tc.method, Path: tc.path})
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			}
+			assert.Equal(t, tc.expectedLocation, resp.Headers["Location"], "Test case %d: %s - Location mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestRouterUseRawPath(t *testing.T) {
+	testCases := []struct {
+		id             int
+		name           string
+		useRawPath     bool
+		path           string
+		expectedStatus int
+		expectedParams map[string]string
+	}{
+		{1, "encoded slash in param", true, "/files/a%2Fb", 200, map[string]string{"name": "a/b"}},
+		{2, "encoded characters in params", true, "/files/my%20file/versions/v%231", 200, map[string]string{"name": "my file", "version": "v#1"}},
+		{3, "encoded wildcard", true, "/static/css%2Fapp/main%20.css", 200, map[string]string{"filepath": "css/app/main .css"}},
+		{4, "invalid escape is kept", true, "/files/100%", 200, map[string]string{"name": "100%"}},
+		{5, "decoded path", true, "/files/a/b", 404, nil},
+		{6, "without raw path", false, "/files/a%2Fb", 200, map[string]string{"name": "a%2Fb"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			var params map[string]string
+			handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				params = req.PathParameters
+				return events.APIGatewayProxyResponse{StatusCode: 200, Body: req.Path}, nil
+			}
+			router := NewLambdaMux()
+			router.UseRawPath(tc.useRawPath)
+			router.GET("/files/:name", handler)
+			router.GET("/files/:name/versions/:version", handler)
+			router.GET("/static/*filepath", handler)
+
+			resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tc.path})
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			assert.Equal(t, tc.expectedParams, params, "Test case %d: %s - Params mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.path, resp.Body, "Test case %d: %s - Path mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestRouterUseRawPathRedirect(t *testing.T) {
+	router := NewLambdaMux()
+	router.UseRawPath(true)
+	router.TrailingSlash(TrailingSlashRedirect)
+	router.GET("/files/:name", pathEchoHandler)
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/files/a%2Fb%20c/"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 301, resp.StatusCode)
+	assert.Equal(t, "/files/a%2Fb%20c", resp.Headers["Location"])
+}
+
+func TestRouterBasePathAndStage(t *testing.T) {
+	testCases := []struct {
+		id               int
+		name             string
+		basePath         string
+		stripStage       bool
+		req              events.APIGatewayProxyRequest
+		expectedStatus   int
+		expectedPath     string
+		expectedLocation string
+	}{
+		{
+			1,
+			"base path",
+			"/api/",
+			false,
+			events.APIGatewayProxyRequest{Path: "/api/users/1"},
+			200,
+			"/users/1",
+			"",
+		},
+		{2, "base path only", "/api", false, events.APIGatewayProxyRequest{Path: "/api"}, 200, "/", ""},
+		{3, "path without base path", "/api", false, events.APIGatewayProxyRequest{Path: "/users/1"}, 200, "/users/1", ""},
+		{4, "base path prefix of a segment", "/api", false, events.APIGatewayProxyRequest{Path: "/apiv2/users/1"}, 404, "", ""},
+		{
+			5,
+			"HTTP API stage",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/prod/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/prod/users/1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			6,
+			"REST API stage is not in the path",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/prod/users/1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			7,
+			"default stage",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "$default"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			8,
+			"REST API base path mapping detected from the proxy resource",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Resource:       "/{proxy+}",
+				Path:           "/api/users/1",
+				PathParameters: map[string]string{"proxy": "users/1"},
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/api/users/1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			9,
+			"nested proxy resource",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Resource:       "/users/{proxy+}",
+				Path:           "/v1/users/1",
+				PathParameters: map[string]string{"proxy": "1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			10,
+			"stage and base path",
+			"/api",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/prod/api/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/prod/api/users/1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			11,
+			"stage not stripped when disabled",
+			"",
+			false,
+			events.APIGatewayProxyRequest{
+				Path:           "/prod/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod"},
+			},
+			404,
+			"",
+			"",
+		},
+		{
+			12,
+			"redirect keeps the prefix",
+			"/api",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/prod/api/users/1/",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/prod/api/users/1/"},
+			},
+			301,
+			"",
+			"/prod/api/users/1",
+		},
+		{
+			13,
+			"REST API route starting with the stage name",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Resource:       "/v1/users/{id}",
+				Path:           "/v1/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "v1", Path: "/v1/v1/users/1"},
+			},
+			200,
+			"/v1/users/1",
+			"",
+		},
+		{
+			14,
+			"stage without the request context path",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Path:           "/prod/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod"},
+			},
+			404,
+			"",
+			"",
+		},
+		{
+			15,
+			"REST API base path mapping detected from a static resource",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Resource:       "/users",
+				Path:           "/api/users",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", Path: "/api/users"},
+			},
+			200,
+			"/users",
+			"",
+		},
+		{
+			16,
+			"REST API base path mapping detected from a param resource",
+			"",
+			true,
+			events.APIGatewayProxyRequest{
+				Resource:       "/users/{id}",
+				Path:           "/api/users/1",
+				PathParameters: map[string]string{"id": "1"},
+			},
+			200,
+			"/users/1",
+			"",
+		},
+		{
+			17,
+			"REST API base path mapping to the root resource",
+			"",
+			true,
+			events.APIGatewayProxyRequest{Resource: "/", Path: "/api"},
+			200,
+			"/",
+			"",
+		},
+		{
+			18,
+			"resource param missing from the path parameters",
+			"",
+			true,
+			events.APIGatewayProxyRequest{Resource: "/users/{id}", Path: "/api/users/1"},
+			404,
+			"",
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			router := NewLambdaMux()
+			router.BasePath(tc.basePath)
+			router.StripStage(tc.stripStage)
+			router.TrailingSlash(TrailingSlashRedirect)
+			router.GET("/", pathEchoHandler)
+			router.GET("/users", pathEchoHandler)
+			router.GET("/users/:id", pathEchoHandler)
+			router.GET("/v1/users/:id", pathEchoHandler)
+
+			tc.req.HTTPMethod = "GET"
+			resp, err := router.Handle(context.Background(), tc.req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.expectedPath, resp.Body, "Test case %d: %s - Path mismatch", tc.id, tc.name)
+			}
+			assert.Equal(t, tc.expectedLocation, resp.Headers["Location"], "Test case %d: %s - Location mismatch", tc.id, tc.name)
+		})
+	}
+}
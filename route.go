@@ -0,0 +1,301 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/D-Andreev/lambdamux/internal/radix"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Route is a registered route. Its methods add matchers on the host, headers or query parameters
+// of the request, so that several routes can be registered for the same method and path.
+type Route struct {
+	mux         *LambdaMux
+	method      string
+	pattern     string
+	handler     HandlerFunc
+	middlewares []MiddlewareFunc
+	chained     HandlerFunc // handler wrapped in the route middleware
+	matchers    []requestMatcher
+	name        string
+	mounted     bool          // the first middleware rewrites the path for a mounted router
+	patterns    []string      // the normalized patterns the route is inserted at
+	nodes       []*radix.Node // the tree nodes of patterns
+	err         error         // the registration error, if the route couldn't be inserted in the tree
+}
+
+// Name sets the name used to build the path of the route with LambdaMux.URL. Names must be unique.
+func (rt *Route) Name(name string) *Route {
+	rt.mux.checkMutable()
+	rt.name = name
+	return rt
+}
+
+// url returns the path of the route with its params replaced by the given values, using the
+// longest of its patterns whose params are exactly the given ones
+func (rt *Route) url(params map[string]string) (string, error) {
+	for i := len(rt.nodes) - 1; i >= 0; i-- {
+		names := rt.nodes[i].Params()
+		if len(names) == len(params) && hasParams(names, params) {
+			return rt.nodes[i].URL(params)
+		}
+	}
+
+	all := rt.nodes[len(rt.nodes)-1].Params()
+	for name := range params {
+		if !slices.Contains(all, name) {
+			return "", fmt.Errorf("unknown param %s", name)
+		}
+	}
+	for _, name := range all {
+		if _, ok := params[name]; !ok {
+			return "", fmt.Errorf("missing param %s", name)
+		}
+	}
+	return "", fmt.Errorf("no pattern matches the given params")
+}
+
+// hasParams reports whether params has a value for each of names
+func hasParams(names []string, params map[string]string) bool {
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Host restricts the route to requests whose host matches pattern. Labels written as {name}
+// match any single label and are added to the path parameters, e.g. {tenant}.api.example.com.
+// Other labels are compared case-insensitively. The host is taken from the Host header, or
+// from RequestContext.DomainName if there is none.
+func (rt *Route) Host(pattern string) *Route {
+	rt.mux.checkMutable()
+	rt.matchers = append(rt.matchers, hostMatcher{pattern: pattern, labels: strings.Split(pattern, ".")})
+	return rt
+}
+
+// Header restricts the route to requests with the header name. If value is not empty, the header
+// or one of its comma-separated elements, ignoring parameters after a semicolon, must equal value
+// case-insensitively, e.g. Header("Accept", "application/vnd.acme.v2+json").
+func (rt *Route) Header(name, value string) *Route {
+	rt.mux.checkMutable()
+	rt.matchers = append(rt.matchers, headerMatcher{name: name, value: value})
+	return rt
+}
+
+// Query restricts the route to requests with the query parameter name. If value is not empty,
+// one of the values of the parameter must equal value.
+func (rt *Route) Query(name, value string) *Route {
+	rt.mux.checkMutable()
+	rt.matchers = append(rt.matchers, queryMatcher{name: name, value: value})
+	return rt
+}
+
+// match reports whether all matchers of the route match the request
+func (rt *Route) match(req events.APIGatewayProxyRequest) bool {
+	for _, m := range rt.matchers {
+		if !m.match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// captures returns the path parameters of the request with the values captured by the host
+// patterns of the route added, or the path parameters as is if the route has no captures
+func (rt *Route) captures(req events.APIGatewayProxyRequest) map[string]string {
+	params := req.PathParameters
+	copied := false
+	for _, m := range rt.matchers {
+		hm, ok := m.(hostMatcher)
+		if !ok || !strings.Contains(hm.pattern, "{") {
+			continue
+		}
+		if !copied {
+			params = make(map[string]string, len(req.PathParameters)+len(hm.labels))
+			for k, v := range req.PathParameters {
+				params[k] = v
+			}
+			copied = true
+		}
+		hm.capture(req, params)
+	}
+	return params
+}
+
+// matcherKey identifies the matchers of the route regardless of their order. Routes with the same
+// method, pattern and matcherKey are duplicates.
+func (rt *Route) matcherKey() string {
+	keys := make([]string, len(rt.matchers))
+	for i, m := range rt.matchers {
+		keys[i] = m.String()
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, " ")
+}
+
+// requestMatcher is a condition on the request, other than its method and path, for a route to match
+type requestMatcher interface {
+	match(req events.APIGatewayProxyRequest) bool
+	String() string
+}
+
+type hostMatcher struct {
+	pattern string
+	labels  []string
+}
+
+func (m hostMatcher) match(req events.APIGatewayProxyRequest) bool {
+	host := requestHost(req)
+	if host == "" || strings.Count(host, ".")+1 != len(m.labels) {
+		return false
+	}
+	for _, label := range m.labels {
+		var value string
+		value, host, _ = strings.Cut(host, ".")
+		if isHostCapture(label) {
+			if value == "" {
+				return false
+			}
+		} else if !strings.EqualFold(label, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// capture adds the labels of the host of a matching request captured by the pattern to params
+func (m hostMatcher) capture(req events.APIGatewayProxyRequest, params map[string]string) {
+	labels := strings.Split(requestHost(req), ".")
+	for i, label := range m.labels {
+		if isHostCapture(label) {
+			params[label[1:len(label)-1]] = labels[i]
+		}
+	}
+}
+
+// isHostCapture reports whether a label of a host pattern is written as {name}
+func isHostCapture(label string) bool {
+	return strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}")
+}
+
+func (m hostMatcher) String() string {
+	return "host=" + strings.ToLower(m.pattern)
+}
+
+type headerMatcher struct {
+	name  string
+	value string
+}
+
+func (m headerMatcher) match(req events.APIGatewayProxyRequest) bool {
+	values, ok := headerValues(req, m.name)
+	if !ok || m.value == "" {
+		return ok
+	}
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			element, _, _ = strings.Cut(element, ";")
+			if strings.EqualFold(strings.TrimSpace(element), m.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m headerMatcher) String() string {
+	return "header=" + strings.ToLower(m.name) + ":" + strings.ToLower(m.value)
+}
+
+type queryMatcher struct {
+	name  string
+	value string
+}
+
+func (m queryMatcher) match(req events.APIGatewayProxyRequest) bool {
+	values, ok := req.MultiValueQueryStringParameters[m.name]
+	if value, single := req.QueryStringParameters[m.name]; single && !ok {
+		values, ok = []string{value}, true
+	}
+	if !ok || m.value == "" {
+		return ok
+	}
+	for _, value := range values {
+		if value == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m queryMatcher) String() string {
+	return "query=" + url.QueryEscape(m.name) + "=" + url.QueryEscape(m.value)
+}
+
+// headerValues returns the values of the header name, looked up case-insensitively
+func headerValues(req events.APIGatewayProxyRequest, name string) ([]string, bool) {
+	for k, v := range req.MultiValueHeaders {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, name) {
+			return []string{v}, true
+		}
+	}
+	return nil, false
+}
+
+// requestHost returns the host of the request without a port
+func requestHost(req events.APIGatewayProxyRequest) string {
+	host := req.RequestContext.DomainName
+	if values, ok := headerValues(req, "Host"); ok && len(values) > 0 {
+		host = values[0]
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return host
+}
+
+// errNoMatchingRoute is returned by a route group when none of its routes match the request
+var errNoMatchingRoute = errors.New("no matching route")
+
+// routeGroup holds the routes registered for the same method and pattern
+type routeGroup struct {
+	node   *radix.Node
+	routes []*Route
+}
+
+// handle calls the handler of the route with the most matchers that match the request, the
+// earliest registered one on a tie, or returns errNoMatchingRoute if no route matches
+func (g *routeGroup) handle(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var best *Route
+	for _, rt := range g.routes {
+		if rt.err != nil || (best != nil && len(rt.matchers) <= len(best.matchers)) {
+			continue
+		}
+		if rt.match(req) {
+			best = rt
+		}
+	}
+	if best == nil {
+		return events.APIGatewayProxyResponse{}, errNoMatchingRoute
+	}
+	req.PathParameters = best.captures(req)
+	resp, err := best.chained(ctx, req)
+	if err != nil && best.mux.errorHandler != nil {
+		// The error handler gets the request as it was routed, with its path params
+		return best.mux.errorHandler(ctx, req, err), nil
+	}
+	return resp, err
+}
@@ -0,0 +1,282 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// bodyHandler returns body and the path parameters in the response body
+func bodyHandler(body string) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: fmt.Sprintf("%s %v", body, req.PathParameters)}, nil
+	}
+}
+
+func TestRouteMatchers(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users/:id", bodyHandler("v1"))
+	router.GET("/users/:id", bodyHandler("v2")).Header("Accept", "application/vnd.acme.v2+json")
+	router.GET("/users/:id", bodyHandler("tenant")).Host("{tenant}.api.example.com")
+	router.GET("/users/:id", bodyHandler("tenant v2")).
+		Host("{tenant}.api.example.com").
+		Header("Accept", "application/vnd.acme.v2+json")
+	router.GET("/reports", bodyHandler("csv")).Query("format", "csv")
+	router.GET("/reports", bodyHandler("debug")).Query("debug", "")
+	router.POST("/reports", bodyHandler("admin")).Host("admin.example.com")
+	router.ANY("/reports", bodyHandler("any")).Header("X-Any", "")
+	assert.NoError(t, router.Validate())
+
+	testCases := []struct {
+		id             int
+		name           string
+		req            events.APIGatewayProxyRequest
+		expectedStatus int
+		expectedBody   string
+	}{
+		{1, "no matchers", events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1"}, 200, "v1 map[id:1]"},
+		{
+			2,
+			"header",
+			events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Path:       "/users/1",
+				Headers:    map[string]string{"accept": "text/html, application/vnd.acme.v2+json;q=0.9"},
+			},
+			200,
+			"v2 map[id:1]",
+		},
+		{
+			3,
+			"other header value",
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1", Headers: map[string]string{"Accept": "application/json"}},
+			200,
+			"v1 map[id:1]",
+		},
+		{
+			4,
+			"host capture",
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1", Headers: map[string]string{"Host": "acme.API.example.com:443"}},
+			200,
+			"tenant map[id:1 tenant:acme]",
+		},
+		{
+			5,
+			"host from the request context",
+			events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Path:           "/users/1",
+				RequestContext: events.APIGatewayProxyRequestContext{DomainName: "acme.api.example.com"},
+			},
+			200,
+			"tenant map[id:1 tenant:acme]",
+		},
+		{
+			6,
+			"most matchers win",
+			events.APIGatewayProxyRequest{
+				HTTPMethod:        "GET",
+				Path:              "/users/1",
+				MultiValueHeaders: map[string][]string{"Host": {"acme.api.example.com"}, "Accept": {"application/vnd.acme.v2+json"}},
+			},
+			200,
+			"tenant v2 map[id:1 tenant:acme]",
+		},
+		{
+			7,
+			"host with more labels",
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/1", Headers: map[string]string{"Host": "eu.acme.api.example.com"}},
+			200,
+			"v1 map[id:1]",
+		},
+		{
+			8,
+			"query value",
+			events.APIGatewayProxyRequest{
+				HTTPMethod:                      "GET",
+				Path:                            "/reports",
+				MultiValueQueryStringParameters: map[string][]string{"format": {"json", "csv"}},
+			},
+			200,
+			"csv map[]",
+		},
+		{
+			9,
+			"query presence",
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/reports", QueryStringParameters: map[string]string{"debug": ""}},
+			200,
+			"debug map[]",
+		},
+		{
+			10,
+			"no route matches",
+			events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/reports", QueryStringParameters: map[string]string{"format": "json"}},
+			404,
+			"",
+		},
+		{
+			11,
+			"no route for the method matches",
+			events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/reports", Headers: map[string]string{"Host": "example.com"}},
+			404,
+			"",
+		},
+		{
+			12,
+			"fall back to ANY",
+			events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/reports", Headers: map[string]string{"Host": "example.com", "X-Any": "1"}},
+			200,
+			"any map[]",
+		},
+		{
+			13,
+			"HEAD falls back to GET",
+			events.APIGatewayProxyRequest{HTTPMethod: "HEAD", Path: "/reports", QueryStringParameters: map[string]string{"format": "csv"}},
+			200,
+			"",
+		},
+		{14, "method not allowed", events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/users/1"}, 405, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			resp, err := router.Handle(context.Background(), tc.req)
+
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode, "Test case %d: %s - Status code mismatch", tc.id, tc.name)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.expectedBody, resp.Body, "Test case %d: %s - Body mismatch", tc.id, tc.name)
+			}
+		})
+	}
+}
+
+func TestRouteMatchersDuplicates(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users/:id", bodyHandler("first")).Host("api.example.com").Header("Accept", "application/json")
+	router.GET("/users/{id}", bodyHandler("second")).Header("accept", "application/JSON").Host("API.example.com")
+	router.GET("/users/:id", bodyHandler("third")).Header("Accept", "text/html")
+	router.GET("/users/:id?", bodyHandler("fourth"))
+	router.GET("/users", bodyHandler("fifth"))
+
+	err := router.Validate()
+
+	assert.EqualError(t, err, `lambdamux: 2 invalid route(s):
+GET /users/{id}: duplicate route: /users/:id is already registered
+GET /users: duplicate route: /users is already registered`)
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/users/1",
+		Headers:    map[string]string{"Host": "api.example.com", "Accept": "application/json"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "first map[id:1]", resp.Body)
+}
+
+func TestMountRouteMatchers(t *testing.T) {
+	users := NewLambdaMux()
+	users.GET("/:id", bodyHandler("v2")).Header("Accept", "application/vnd.acme.v2+json")
+	users.GET("/:id", bodyHandler("v1"))
+
+	router := NewLambdaMux()
+	router.Mount("/users", users)
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/users/1",
+		Headers:    map[string]string{"Accept": "application/vnd.acme.v2+json"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "v2 map[id:1]", resp.Body)
+}
+
+func TestRouterURL(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users/:id", bodyHandler("user")).Name("user.show")
+	router.GET("/accounts/{userId}/orders/:id<int>", bodyHandler("order")).Name("order.show")
+	router.GET("/archive/:year?/:month?", bodyHandler("archive")).Name("archive")
+	router.GET("/static/*filepath", bodyHandler("static")).Name("static")
+	router.Group("/admin", func(g *Group) {
+		g.GET("/settings", bodyHandler("settings")).Name("admin.settings")
+	})
+	assert.NoError(t, router.Validate())
+
+	testCases := []struct {
+		id            int
+		name          string
+		route         string
+		pairs         []string
+		expected      string
+		expectedError string
+	}{
+		{1, "single param", "user.show", []string{"id", "42"}, "/users/42", ""},
+		{2, "escaped param", "user.show", []string{"id", "a b/c"}, "/users/a%20b%2Fc", ""},
+		{3, "several params", "order.show", []string{"id", "7", "userId", "42"}, "/accounts/42/orders/7", ""},
+		{4, "group route", "admin.settings", nil, "/admin/settings", ""},
+		{5, "without optional params", "archive", nil, "/archive", ""},
+		{6, "with an optional param", "archive", []string{"year", "2024"}, "/archive/2024", ""},
+		{7, "with all optional params", "archive", []string{"year", "2024", "month", "05"}, "/archive/2024/05", ""},
+		{8, "wildcard", "static", []string{"filepath", "css/app.css"}, "/static/css/app.css", ""},
+		{9, "unknown route", "user.delete", nil, "", "lambdamux: no route named user.delete"},
+		{10, "missing param", "order.show", []string{"id", "7"}, "", "lambdamux: route order.show: missing param userId"},
+		{11, "extra param", "user.show", []string{"id", "42", "format", "json"}, "", "lambdamux: route user.show: unknown param format"},
+		{12, "odd pairs", "user.show", []string{"id"}, "", "lambdamux: route user.show: odd number of param name and value pairs"},
+		{
+			13,
+			"constraint",
+			"order.show",
+			[]string{"userId", "42", "id", "seven"},
+			"",
+			`lambdamux: route order.show: param id: value "seven" doesn't satisfy constraint int`,
+		},
+		{14, "optional params out of order", "archive", []string{"month", "05"}, "", "lambdamux: route archive: missing param year"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d: %s", tc.id, tc.name), func(t *testing.T) {
+			url, err := router.URL(tc.route, tc.pairs...)
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError, "Test case %d: %s - Error mismatch", tc.id, tc.name)
+				return
+			}
+			assert.NoError(t, err, "Test case %d: %s - Unexpected error", tc.id, tc.name)
+			assert.Equal(t, tc.expected, url, "Test case %d: %s - URL mismatch", tc.id, tc.name)
+		})
+	}
+}
+
+func TestRouterURLDuplicateName(t *testing.T) {
+	users := NewLambdaMux()
+	users.GET("/:id", bodyHandler("user")).Name("user.show")
+
+	router := NewLambdaMux()
+	router.Mount("/users", users)
+	router.GET("/accounts/:id", bodyHandler("account")).Name("user.show")
+
+	assert.EqualError(t, router.Validate(), `lambdamux: 1 invalid route(s):
+GET /accounts/:id: duplicate route: name user.show is already used by /users/:id`)
+
+	url, err := router.URL("user.show", "id", "42")
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/42", url)
+}
+
+func TestRouteOptionalParamsConflict(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/a/:y", bodyHandler("y"))
+	router.GET("/a/:x?", bodyHandler("x"))
+
+	assert.ErrorIs(t, router.Validate(), ErrRouteConflict)
+
+	for _, method := range []string{"GET", "POST"} {
+		resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: method, Path: "/a"})
+		assert.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode, method)
+		assert.Empty(t, resp.Headers["Allow"], method)
+	}
+}
@@ -0,0 +1,69 @@
+package lambdamux
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method      string   // the HTTP method, or ANY for routes matching every method
+	Pattern     string   // the pattern as registered, including the prefix of groups and mounted routers
+	Params      []string // the names of the params and the wildcard in the pattern, in the order they appear
+	Name        string   // the name set with Route.Name, if any
+	Middlewares int      // the number of group and route middleware, not counting the router middleware
+}
+
+// Routes returns the routes that were registered without an error, in registration order
+func (r *LambdaMux) Routes() []RouteInfo {
+	var routes []RouteInfo
+	_ = r.Walk(func(info RouteInfo) error {
+		routes = append(routes, info)
+		return nil
+	})
+	return routes
+}
+
+// Walk calls fn for each route that was registered without an error, in registration order.
+// Walk stops and returns the error if fn returns one.
+func (r *LambdaMux) Walk(fn func(info RouteInfo) error) error {
+	for _, rt := range r.routes {
+		if rt.err != nil {
+			continue
+		}
+		if err := fn(rt.info()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintRoutes writes a table of the routes returned by Routes to w, one route per line
+func (r *LambdaMux) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tPARAMS\tNAME\tMIDDLEWARE")
+	for _, info := range r.Routes() {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%d\n",
+			info.Method, info.Pattern, strings.Join(info.Params, ","), info.Name, info.Middlewares,
+		)
+	}
+	return tw.Flush()
+}
+
+// info returns the description of a route registered without an error
+func (rt *Route) info() RouteInfo {
+	middlewares := len(rt.middlewares)
+	if rt.mounted {
+		middlewares--
+	}
+	return RouteInfo{
+		Method:      methodName(rt.method),
+		Pattern:     rt.pattern,
+		Params:      rt.nodes[len(rt.nodes)-1].Params(),
+		Name:        rt.name,
+		Middlewares: middlewares,
+	}
+}
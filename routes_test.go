@@ -0,0 +1,68 @@
+package lambdamux
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterRoutes(t *testing.T) {
+	var calls []string
+	passthrough := recordingMiddleware("passthrough", &calls)
+	users := NewLambdaMux()
+	users.Use(passthrough)
+	users.GET("/:id", bodyHandler("user")).Name("user.show")
+
+	router := NewLambdaMux()
+	router.Use(passthrough)
+	router.GET("/", bodyHandler("index"))
+	router.POST("/orders/{orderId}/items/:id<int>", bodyHandler("item"), passthrough)
+	router.GET("/orders/:id", bodyHandler("invalid"))
+	router.ANY("/static/*filepath", bodyHandler("static")).Name("static")
+	router.Group("/admin", func(g *Group) {
+		g.Use(passthrough)
+		g.GET("/archive/:year?", bodyHandler("archive"), passthrough)
+	})
+	router.Mount("/users", users)
+
+	expected := []RouteInfo{
+		{Method: "GET", Pattern: "/"},
+		{Method: "POST", Pattern: "/orders/{orderId}/items/:id<int>", Params: []string{"orderId", "id"}, Middlewares: 1},
+		{Method: "ANY", Pattern: "/static/*filepath", Params: []string{"filepath"}, Name: "static"},
+		{Method: "GET", Pattern: "/admin/archive/:year?", Params: []string{"year"}, Middlewares: 2},
+		{Method: "GET", Pattern: "/users/:id", Params: []string{"id"}, Name: "user.show", Middlewares: 1},
+	}
+	assert.Equal(t, expected, router.Routes())
+
+	var b bytes.Buffer
+	assert.NoError(t, router.PrintRoutes(&b))
+	assert.Equal(t, `METHOD  PATTERN                           PARAMS      NAME       MIDDLEWARE
+GET     /                                                        0
+POST    /orders/{orderId}/items/:id<int>  orderId,id             1
+ANY     /static/*filepath                 filepath    static     0
+GET     /admin/archive/:year?             year                   2
+GET     /users/:id                        id          user.show  1
+`, b.String())
+}
+
+func TestRouterWalk(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/users", bodyHandler("users"))
+	router.GET("/users/:id", bodyHandler("user"))
+	router.DELETE("/users/:id", bodyHandler("delete"))
+
+	errStop := errors.New("stop")
+	var patterns []string
+	err := router.Walk(func(info RouteInfo) error {
+		patterns = append(patterns, info.Method+" "+info.Pattern)
+		if info.Params != nil {
+			return errStop
+		}
+		return nil
+	})
+
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, []string{"GET /users", "GET /users/:id"}, patterns)
+}
@@ -0,0 +1,151 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// StreamingHandlerFunc defines the function signature for handlers that write the response body to a stream
+type StreamingHandlerFunc func(context.Context, events.APIGatewayProxyRequest, *StreamWriter) error
+
+// StreamWriter is used by a StreamingHandlerFunc to set the response status and headers and write the body.
+// The status code and headers must be set before the first call to Write.
+type StreamWriter struct {
+	statusCode int
+	headers    map[string]string
+	w          io.Writer
+	commit     func(*StreamWriter)
+	committed  bool
+}
+
+// Header returns the response headers. Changes made after the first call to Write have no effect.
+func (w *StreamWriter) Header() map[string]string {
+	return w.headers
+}
+
+// WriteHeader sets the response status code. Calls made after the first call to Write have no effect.
+func (w *StreamWriter) WriteHeader(statusCode int) {
+	if w.committed {
+		return
+	}
+	w.statusCode = statusCode
+}
+
+// Write writes p to the response body
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	w.commitHeaders()
+	return w.w.Write(p)
+}
+
+// commitHeaders fixes the status code and headers of the response
+func (w *StreamWriter) commitHeaders() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	if w.commit != nil {
+		w.commit(w)
+	}
+}
+
+// Stream adapts a StreamingHandlerFunc to a HandlerFunc so it can be registered like any other route.
+// When the request is served by HandleFunctionURLStreaming, the body is streamed to the client as it is
+// written: the returned response holds the status code and headers once they are committed, so that
+// middleware can still change them, while the handler keeps writing the body. Changes middleware makes
+// to the body of a streamed response are not sent. For every other entry point the body is buffered
+// and returned as a regular response. The body written for a HEAD request is discarded.
+func Stream(handler StreamingHandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if sink, ok := ctx.Value(streamSinkKey{}).(*streamSink); ok {
+			return sink.serve(ctx, req, handler)
+		}
+
+		var body bytes.Buffer
+		w := &StreamWriter{statusCode: http.StatusOK, headers: map[string]string{}, w: &body}
+		if err := handler(ctx, req, w); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+
+		resp := events.APIGatewayProxyResponse{StatusCode: w.statusCode, Headers: w.headers}
+		if utf8.Valid(body.Bytes()) {
+			resp.Body = body.String()
+		} else {
+			resp.Body = base64.StdEncoding.EncodeToString(body.Bytes())
+			resp.IsBase64Encoded = true
+		}
+		return resp, nil
+	}
+}
+
+type streamSinkKey struct{}
+
+// streamSink connects a streaming handler to the body of a streaming response
+type streamSink struct {
+	pw       *io.PipeWriter
+	streamed bool // set once a streaming handler has committed its headers
+}
+
+// serve runs handler in its own goroutine, writing directly to the response stream, and returns
+// once the headers are committed: on the first write, or when the handler returns without error.
+// An error or panic before that is returned, after that it is reported by the body.
+func (s *streamSink) serve(
+	ctx context.Context,
+	req events.APIGatewayProxyRequest,
+	handler StreamingHandlerFunc,
+) (events.APIGatewayProxyResponse, error) {
+	body := io.Writer(s.pw)
+	if req.HTTPMethod == http.MethodHead {
+		// HEAD responses have no body, also when the request is served by a GET route
+		body = io.Discard
+	}
+
+	var resp events.APIGatewayProxyResponse
+	committed := make(chan struct{})
+	w := &StreamWriter{
+		statusCode: http.StatusOK,
+		headers:    map[string]string{},
+		w:          body,
+		commit: func(w *StreamWriter) {
+			resp = events.APIGatewayProxyResponse{StatusCode: w.statusCode, Headers: maps.Clone(w.headers)}
+			close(committed)
+		},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() {
+			// A panic would crash the process outside of the invocation, so it is reported as an error
+			if p := recover(); p != nil {
+				err = fmt.Errorf("lambdamux: handler panicked: %v", p)
+			}
+			if w.committed {
+				_ = s.pw.CloseWithError(err)
+			}
+			done <- err
+		}()
+		if err = handler(ctx, req, w); err == nil {
+			w.commitHeaders()
+		}
+	}()
+
+	select {
+	case <-committed:
+	case err := <-done:
+		select {
+		case <-committed:
+		default:
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+	s.streamed = true
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamBuffered(t *testing.T) {
+	router := NewLambdaMux()
+	router.GET("/report", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		w.Header()["Content-Type"] = "text/plain"
+		_, _ = w.Write([]byte("hello "))
+		_, _ = w.Write([]byte("world"))
+		return nil
+	}))
+	router.GET("/binary", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		w.WriteHeader(201)
+		_, _ = w.Write([]byte{0xff, 0xfe, 0x00})
+		return nil
+	}))
+	router.GET("/fail", Stream(func(ctx context.Context, req events.APIGatewayProxyRequest, w *StreamWriter) error {
+		return errors.New("fail")
+	}))
+
+	resp, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/report"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "hello world", resp.Body)
+	assert.False(t, resp.IsBase64Encoded)
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain"}, resp.Headers)
+
+	resp, err = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/binary"})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.True(t, resp.IsBase64Encoded)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00}), resp.Body)
+
+	_, err = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/fail"})
+	assert.EqualError(t, err, "fail")
+}